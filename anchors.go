@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"html"
+)
+
+// idRegistry hands out HTML ids which are unique within a document.
+// The ids depend on the document only, which is why they remain stable when other pages change.
+type idRegistry struct {
+	used    map[string]bool
+	counter int
+	// The ids of the footnotes and of their first references indexed by footnote number, see `footnoteIDs`
+	footnotes map[int][2]string
+}
+
+// ids returns the id registry of the document to which the node belongs.
+// When the registry is created, all labels used in the document are reserved
+// and all headings are assigned an id derived from their text.
+func (node *DocumentNode) ids() *idRegistry {
+	root := node
+	if node.Document != nil {
+		root = node.Document
+	}
+	if root.idRegistry != nil {
+		return root.idRegistry
+	}
+	reg := &idRegistry{used: make(map[string]bool)}
+	root.idRegistry = reg
+	var reserve func(n *DocumentNode)
+	reserve = func(n *DocumentNode) {
+		if label, ok := n.Attributes["label"]; ok {
+			reg.used[label] = true
+		}
+		for _, c := range n.Children {
+			reserve(c)
+		}
+	}
+	reserve(root)
+	for _, e := range root.Entities() {
+		if label, ok := e.Attributes["label"]; ok {
+			reg.used[label] = true
+		}
+	}
+	for _, s := range root.styles() {
+		if label, ok := s.Attributes["label"]; ok {
+			reg.used[label] = true
+		}
+	}
+	// Headings are processed in document order, hence the first of two equal headings gets the id without suffix
+	var assign func(n *DocumentNode)
+	assign = func(n *DocumentNode) {
+		if n.isAnchoredHeading() && n.forcedID == "" {
+			if _, ok := n.Attributes["label"]; !ok {
+				slug := slugify(n.PlainText())
+				if slug == "" {
+					slug = "section"
+				}
+				n.forcedID = reg.unique(slug)
+			}
+		}
+		for _, c := range n.Children {
+			assign(c)
+		}
+	}
+	assign(root)
+	return reg
+}
+
+// unique returns `base` if it is still unused, and otherwise `base` followed by the first free numeric suffix.
+func (reg *idRegistry) unique(base string) string {
+	id := base
+	for i := 1; reg.used[id]; i++ {
+		id = fmt.Sprintf("%v-%v", base, i)
+	}
+	reg.used[id] = true
+	return id
+}
+
+// next returns a new id for a node that has neither a label nor a heading text.
+func (reg *idRegistry) next() string {
+	for {
+		id := fmt.Sprintf("_id_%v", reg.counter)
+		reg.counter++
+		if !reg.used[id] {
+			reg.used[id] = true
+			return id
+		}
+	}
+}
+
+// isAnchoredHeading returns true if the node is a heading that is automatically assigned an id.
+// Headings with the attribute "noid" opt out.
+func (node *DocumentNode) isAnchoredHeading() bool {
+	return node.TagDefinition != nil && node.TagDefinition.Heading > 0 && !node.HasClass("noid")
+}
+
+// Anchor returns a link to the node itself, which heading templates place next to the heading text.
+// The result is empty unless "HeadingAnchors" is set to true in the page config or in site.yaml.
+func (ctx *NodeContext) Anchor() string {
+	if ctx.node == nil || ctx.ID() == "" {
+		return ""
+	}
+	enabled, ok := ctx.gen.page.Params["HeadingAnchors"]
+	if !ok {
+		if s, isSite := ctx.gen.pageContext.siteContext.(*SiteContext); isSite {
+			enabled = s.Params["HeadingAnchors"]
+		}
+	}
+	if enabled != "true" {
+		return ""
+	}
+	return `<a class="anchor" href="#` + html.EscapeString(ctx.ID()) + `" aria-hidden="true">#</a>`
+}
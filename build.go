@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"log"
 	"net/url"
 	"os"
 	"path/filepath"
@@ -27,6 +28,21 @@ type Builder struct {
 	folderContext map[string]*FolderContext
 	pageTypes     map[string]*pageType
 	copied        map[string]bool
+	// All known output formats by name
+	outputFormats map[string]*OutputFormat
+	// Settings of the search index
+	search *searchConfig
+	// Path of the link graph in the output file system, as specified by "LinkGraph" in site.yaml, or empty if none is generated
+	linkGraph string
+	// Output of formulas ("mathml" or "tex") unless specified by the page type, as specified by "Math" in site.yaml
+	math string
+	// Output of charts ("svg" or "js"), as specified by "Charts" in site.yaml
+	charts string
+	// Number of words of automatic summaries unless specified by the page type, as specified by "SummaryLength" in site.yaml
+	summaryLength int
+	// Settings of the image pipeline as specified by "Images" in site.yaml, and the pipeline shared by all pages
+	images        *imageConfig
+	imagePipeline *imagePipeline
 	// May be nil
 	bundle                *bundle
 	defaultPageType       *pageType
@@ -40,7 +56,7 @@ func newBuilder(options *options) (*Builder, error) {
 	// Create the builder
 	pageTypes := make(map[string]*pageType)
 	folderContext := make(map[string]*FolderContext)
-	b := &Builder{generators: make(map[string]*HTMLGenerator), options: options, pageTypes: pageTypes, folderContext: folderContext, copied: make(map[string]bool)}
+	b := &Builder{generators: make(map[string]*HTMLGenerator), options: options, pageTypes: pageTypes, folderContext: folderContext, copied: make(map[string]bool), outputFormats: newBuiltinOutputFormats(), search: newSearchConfig(), summaryLength: defaultSummaryLength, images: &imageConfig{cache: defaultImageCache}}
 
 	// The file system to use for the build.
 	// Input and output files are located here.
@@ -160,6 +176,32 @@ func newBuilder(options *options) (*Builder, error) {
 			if err != nil {
 				return nil, err
 			}
+		case "OutputFormats":
+			err = addOutputFormatsFromYaml(b.outputFormats, v, "site.yaml")
+			if err != nil {
+				return nil, err
+			}
+		case "Search":
+			err = b.search.addFromYaml(v, "site.yaml")
+			if err != nil {
+				return nil, err
+			}
+		case "LinkGraph":
+			b.linkGraph, err = yamlString(k, v, "site.yaml")
+		case "Math":
+			b.math, err = yamlString(k, v, "site.yaml")
+			if err == nil && b.math != "mathml" && b.math != "tex" {
+				err = fmt.Errorf("In site.yaml: Math must be either mathml or tex")
+			}
+		case "SummaryLength":
+			b.summaryLength, err = parseSummaryLength(v, "site.yaml")
+		case "Charts":
+			b.charts, err = yamlString(k, v, "site.yaml")
+			if err == nil && b.charts != "svg" && b.charts != "js" {
+				err = fmt.Errorf("In site.yaml: Charts must be either svg or js")
+			}
+		case "Images":
+			err = b.images.addFromYaml(v, "site.yaml", true)
 		default:
 			b.site.ctx.Params[k] = v
 		}
@@ -190,6 +232,13 @@ func newBuilder(options *options) (*Builder, error) {
 		return nil, err
 	}
 
+	// Processed images are kept between builds
+	b.imagePipeline = newImagePipeline(afero.NewBasePathFs(b.site.siteFs, filepath.FromSlash(b.images.cache)))
+
+	if b.search.index != "" {
+		b.site.ctx.SearchIndex = "/" + filepath.ToSlash(b.search.index)
+	}
+
 	return b, nil
 }
 
@@ -201,6 +250,15 @@ func (b *Builder) build() error {
 	if err := b.generate(); err != nil {
 		return err
 	}
+	if err := b.generateSearchIndex(); err != nil {
+		return err
+	}
+	if err := b.generateLinkGraph(); err != nil {
+		return err
+	}
+	if b.options.checkLinks {
+		return b.checkLinks()
+	}
 	return nil
 }
 
@@ -327,6 +385,15 @@ func (b *Builder) parseFile(path string, kind pageTypeKind, folderContext *Folde
 	}
 	// Create a parser for the page
 	parser = NewParser(g, markdown, contentResolver)
+	parser.EnableIncludes(filepath.ToSlash(path), func(fname string) ([]byte, error) {
+		return afero.ReadFile(b.contentFs, filepath.FromSlash(fname))
+	})
+	parser.EnableSourceFiles(func(fname string) ([]byte, error) {
+		if !strings.HasPrefix(fname, "/") {
+			fname = filepath.ToSlash(b.site.contentPath) + "/" + fname
+		}
+		return afero.ReadFile(b.site.siteFs, filepath.Clean(filepath.FromSlash("/"+fname)))
+	})
 
 	// Parse and process frontmatter
 	frontmatter, err = parser.ParseFrontmatter()
@@ -382,9 +449,9 @@ func (b *Builder) parseFile(path string, kind pageTypeKind, folderContext *Folde
 			} else {
 				return fmt.Errorf("In %v %v: YAML field 'Scripts' must be a string or list of string", path, k)
 			}
-		case "Title":
+		case "Title", "Summary", "TOCLevels", "HeadingAnchors":
 			if _, ok := v.(string); !ok {
-				return fmt.Errorf("In %v %v: YAML field 'Title' must be a string", path, k)
+				return fmt.Errorf("In %v %v: YAML field '%v' must be a string", path, k, k)
 			}
 		case "Type":
 			pageTypeName, err := yamlString("Type", v, path)
@@ -408,7 +475,7 @@ func (b *Builder) parseFile(path string, kind pageTypeKind, folderContext *Folde
 
 	for k, v := range frontmatter {
 		switch k {
-		case "Scripts", "Styles", "Type", "Title":
+		case "Scripts", "Styles", "Type", "Title", "Summary", "TOCLevels", "HeadingAnchors":
 			// Handled above
 			break
 		default:
@@ -478,48 +545,65 @@ func (b *Builder) parseFile(path string, kind pageTypeKind, folderContext *Folde
 		doc = NewDocument(g)
 	}
 
-	var base string
-	var baseFile string
-	var layouts []string
+	var outputs []*OutputTemplate
+	var partials map[string]string
 	if !pt.isNone() {
-		// Lookup base and layout(s) HTML files.
-		// Find all resources referenced in these HTML files.
-		base, baseFile, err = b.lookupBaseHTML(pt, kind, folderContext)
+		var partialRes []*Resource
+		partials, partialRes, err = b.lookupPartials(pt)
 		if err != nil {
-			return err
+			return fmt.Errorf("In %v: page type %v: %v", path, pt.name, err)
 		}
-		if baseFile != "" {
-			baseResolver := func(res *Resource) error {
-				return pt.resolveStaticResource(res, pt)
+		res = append(res, partialRes...)
+
+		// Lookup base and layout templates for all output formats of the page type.
+		// Find all resources referenced in these files.
+		for _, formatName := range pt.outputFormatNames() {
+			f, ok := b.outputFormats[formatName]
+			if !ok {
+				return fmt.Errorf("In %v: page type %v: unknown output format %v", path, pt.name, formatName)
 			}
-			var baseRes []*Resource
-			base, baseRes, err = ParseHTMLResources(base, baseResolver)
-			if err != nil {
+			base, baseFile, err := b.lookupBase(pt, f, kind, folderContext)
+			if os.IsNotExist(err) {
+				return fmt.Errorf("In %v: page type %v has no base.%v template", path, pt.name, f.Name)
+			} else if err != nil {
 				return err
 			}
-			res = append(res, baseRes...)
-		}
-		layoutData, _, err := b.lookupLayoutHTML(pt, kind, folderContext)
-		if err != nil && !os.IsNotExist(err) {
-			return err
-		}
-		for i := 0; i < len(layoutData); i++ {
-			layoutStr := layoutData[i]
-			layoutResolver := func(res *Resource) error {
-				return pt.resolveStaticResource(res, pt)
+			if baseFile != "" {
+				baseResolver := func(res *Resource) error {
+					return pt.resolveStaticResource(res, pt)
+				}
+				var baseRes []*Resource
+				base, baseRes, err = ParseHTMLResources(base, baseResolver)
+				if err != nil {
+					return err
+				}
+				res = append(res, baseRes...)
 			}
-			var layoutRes []*Resource
-			layoutStr, layoutRes, err = ParseHTMLResources(layoutStr, layoutResolver)
-			if err != nil {
+			layoutData, _, err := b.lookupLayout(pt, f, kind, folderContext)
+			if err != nil && !os.IsNotExist(err) {
 				return err
 			}
-			layouts = append(layouts, layoutStr)
-			res = append(res, layoutRes...)
+			var layouts []string
+			for i := 0; i < len(layoutData); i++ {
+				layoutStr := layoutData[i]
+				layoutResolver := func(res *Resource) error {
+					return pt.resolveStaticResource(res, pt)
+				}
+				var layoutRes []*Resource
+				layoutStr, layoutRes, err = ParseHTMLResources(layoutStr, layoutResolver)
+				if err != nil {
+					return err
+				}
+				layouts = append(layouts, layoutStr)
+				res = append(res, layoutRes...)
+			}
+			outputs = append(outputs, &OutputTemplate{Format: f, Base: base, Layouts: layouts})
 		}
 	}
 
 	// Compute a sensible title
-	if _, ok := frontmatter["Title"]; !ok {
+	_, hasTitle := frontmatter["Title"]
+	if !hasTitle {
 		title := stripSuffix(filepath.Base(path))
 		if title == "index" {
 			if path == "." {
@@ -531,20 +615,44 @@ func (b *Builder) parseFile(path string, kind pageTypeKind, folderContext *Folde
 		frontmatter["Title"] = title
 	}
 
+	// Determine the heading levels listed in the table of contents
+	tocLevels := "1-4"
+	if v, ok := frontmatter["TOCLevels"]; ok {
+		tocLevels = v.(string)
+	} else if spec := pt.tocLevels(); spec != "" {
+		tocLevels = spec
+	}
+	tocFrom, tocTo, err := parseTOCLevels(tocLevels)
+	if err != nil {
+		return fmt.Errorf("In %v: %v", path, err)
+	}
+
 	// Create the page
-	page := &Page{Grammar: g, Document: doc, Fname: path, Resources: res, Params: frontmatter, PageTypeName: pt.name}
+	page := &Page{Grammar: g, Document: doc, Fname: path, DerivedTitle: !hasTitle, Resources: res, Params: frontmatter, PageTypeName: pt.name, Tags: tags, Dependencies: parser.Includes, TOCFrom: tocFrom, TOCTo: tocTo}
+	page.HighlightTheme, page.HighlightInline = pt.highlighting()
+	page.MathML = pt.mathML(b.math)
+	page.SummaryLength = pt.summaryLength(b.summaryLength)
+	page.SVGCharts = b.charts == "svg"
+	page.Images = pt.imageSettings(b.images, b.imagePipeline)
 	if pt.isNone() {
 		// Do not generate a file for this page
 		page.Fname = ""
 	} else {
-		// Set the RelURL property
-		outpath := strings.TrimSuffix(path, ".md") + ".html"
-		page.RelURL = "/" + filepath.ToSlash(outpath)
+		// Set the Outputs and RelURL properties.
+		// The first output format listed by the page type determines the RelURL of the page.
+		for _, o := range outputs {
+			outpath := strings.TrimSuffix(path, ".md") + o.Format.Extension
+			page.Outputs = append(page.Outputs, &PageOutput{Format: o.Format, RelURL: "/" + filepath.ToSlash(outpath)})
+		}
+		if len(page.Outputs) > 0 {
+			page.RelURL = page.Outputs[0].RelURL
+		}
 	}
 
 	// Create the generator for the page
-	gen := NewHTMLGenerator(page, base, layouts, contentResolver, folderContext, b.site.ctx, &b.options.Options)
+	gen := NewHTMLGenerator(page, outputs, partials, contentResolver, folderContext, b.site.ctx, &b.options.Options)
 	b.generators[path] = gen
+	b.site.pages[filepath.ToSlash(path)] = gen.PageContext()
 
 	// Parse all templates and determine resources
 	err = gen.Prepare()
@@ -599,26 +707,36 @@ func (b *Builder) generate() error {
 		}
 	*/
 
-	// Generate HTML files
-	for path, gen := range b.generators {
+	// Generate HTML files and all other output formats.
+	// The links in the generated HTML files determine the backlinks of all pages.
+	links := make(map[*PageOutput][]string)
+	for _, gen := range b.generators {
 		if gen.Page().Fname == "" {
 			continue
 		}
-		println("Generating", gen.Page().Fname, "...")
-		html, err := gen.Generate()
-		if err != nil {
-			return err
+		for _, o := range gen.Page().Outputs {
+			println("Generating", gen.Page().Fname, "as", o.Format.Name, "...")
+			data, err := b.generateOutput(gen, o)
+			if err != nil {
+				return err
+			}
+			if o.Format.isHTML() {
+				links[o] = htmlLinks([]byte(data))
+			}
 		}
-		outpath := strings.TrimSuffix(path, ".md") + ".html"
-		dir := filepath.Dir(outpath)
-		err = b.outputFs.MkdirAll(dir, 0775)
-		if err != nil {
-			return err
+	}
+	b.collectLinks(links)
+
+	// Templates showing backlinks, outbound links or orphans are executed again, now that the links are known.
+	// The content of the page is not rendered again, see `PageContext.Content`.
+	for _, gen := range b.generators {
+		if gen.Page().Fname == "" || !gen.usesLinks() {
+			continue
 		}
-		// println("Writing", outpath, "...")
-		err = afero.WriteFile(b.outputFs, outpath, []byte(html), 0660)
-		if err != nil {
-			return err
+		for _, o := range gen.Page().Outputs {
+			if _, err := b.generateOutput(gen, o); err != nil {
+				return err
+			}
 		}
 	}
 
@@ -648,6 +766,23 @@ func (b *Builder) generate() error {
 	return nil
 }
 
+// generateOutput generates and writes one output format of a page and returns the generated data.
+func (b *Builder) generateOutput(gen *HTMLGenerator, o *PageOutput) (string, error) {
+	data, err := gen.Generate(o.Format)
+	if err != nil {
+		return "", err
+	}
+	outpath := filepath.FromSlash(strings.TrimPrefix(o.RelURL, "/"))
+	dir := filepath.Dir(outpath)
+	err = b.outputFs.MkdirAll(dir, 0775)
+	if err != nil {
+		return "", err
+	}
+	// println("Writing", outpath, "...")
+	err = afero.WriteFile(b.outputFs, outpath, []byte(data), 0660)
+	return data, err
+}
+
 // newFolderContext returns a FolderContext object for the specified path.
 // It searches for "folder.yaml" in this path and processes it, if it exists.
 // Otherwise, default values are used.
@@ -756,10 +891,10 @@ func (b *Builder) lookupMarkdownSyntax(pt *pageType) (data [][]byte, files []str
 	return
 }
 
-func (b *Builder) lookupBaseHTML(pt *pageType, kind pageTypeKind, ctx *FolderContext) (string, string, error) {
+func (b *Builder) lookupBase(pt *pageType, f *OutputFormat, kind pageTypeKind, ctx *FolderContext) (string, string, error) {
 	for pt != nil {
 		// Look in the page type
-		p, path, err := pt.getBaseHTML()
+		p, path, err := pt.getBase(f)
 		if err == nil {
 			return p, path, nil
 		} else if !os.IsNotExist(err) {
@@ -770,10 +905,10 @@ func (b *Builder) lookupBaseHTML(pt *pageType, kind pageTypeKind, ctx *FolderCon
 	return "", "", os.ErrNotExist
 }
 
-func (b *Builder) lookupLayoutHTML(pt *pageType, kind pageTypeKind, ctx *FolderContext) (data []string, files []string, err error) {
+func (b *Builder) lookupLayout(pt *pageType, f *OutputFormat, kind pageTypeKind, ctx *FolderContext) (data []string, files []string, err error) {
 	for pt != nil {
 		// Look in the page type
-		p, path, err := pt.getLayoutHTML()
+		p, path, err := pt.getLayout(f)
 		if err == nil {
 			data = append(data, p)
 			files = append(files, path)
@@ -831,6 +966,16 @@ func (b *Builder) lookupPageType(pageTypeName string) (*pageType, error) {
 		}
 	}
 
+	// The builtin search page type is used unless the site or bundle provide one of their own.
+	if !ok && pageTypeName == "search" {
+		if b.search.index == "" {
+			log.Printf("The search page type requires a search index. Add a Search map to site.yaml")
+		}
+		pt := newSearchPageType()
+		b.pageTypes[pageTypeName] = pt
+		return pt, nil
+	}
+
 	// Error, if there is no specification for the page type.
 	if !ok {
 		//		panic("Oooops")
@@ -0,0 +1,359 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// chartAxis maps the values of one axis of a chart to the plot and labels its ticks.
+//
+//	#chart;xtitle:Week;ytitle:Visitors;yunit:k;ylog;y2:Revenue;y2unit:€;yref:50=Target,40..60=Normal
+//
+// The attributes `xtitle`, `ytitle` and `y2title` label the axes, `xunit`, `yunit` and `y2unit` are appended to the tick labels.
+// `xformat`, `yformat` and `y2format` are either "si" for SI prefixes like "1.5k", "thousands" for digit grouping like "1,500",
+// or a printf format like "%.1f". On time axes, the format is a Go time layout like "Jan 2".
+// `xlog`, `ylog` and `y2log` use a logarithmic scale. `y2` lists the series shown on the secondary y-axis at the right.
+// `xref`, `yref` and `y2ref` draw reference lines like "50" or bands like "40..60", optionally labeled like "50=Target".
+type chartAxis struct {
+	min, max float64
+	log      bool
+	// Values of a time axis are seconds since 1970
+	time   bool
+	ticks  []float64
+	step   float64
+	format string
+	unit   string
+	title  string
+	refs   []chartRef
+}
+
+// chartRef is a reference line or, if `from` and `to` differ, a reference band.
+type chartRef struct {
+	from, to float64
+	label    string
+}
+
+// newChartAxis creates an axis for values from `min` to `max`.
+// If `nice` is true, the range is extended to the outermost ticks.
+func newChartAxis(min, max float64, logScale, timeScale, nice bool) *chartAxis {
+	a := &chartAxis{log: logScale, time: timeScale && !logScale}
+	if a.log {
+		// A logarithmic scale cannot show zero or negative values
+		if max <= 0 {
+			min, max = 1, 10
+		} else if min <= 0 {
+			min = max / 1000
+		}
+	}
+	if max <= min && a.log {
+		min, max = min/10, max*10
+	} else if max <= min {
+		min, max = min-1, max+1
+	}
+	a.min, a.max = min, max
+	a.update(nice)
+	return a
+}
+
+// update computes the ticks of the axis and, if `nice` is true, extends the range to the outermost ticks.
+func (a *chartAxis) update(nice bool) {
+	switch {
+	case a.log:
+		a.ticks, a.step = logTicks(a.min, a.max)
+	case a.time:
+		a.ticks, a.step = timeTicks(a.min, a.max)
+	default:
+		a.ticks, a.step = niceTicks(a.min, a.max)
+	}
+	if nice && len(a.ticks) > 0 && !a.time {
+		a.min, a.max = math.Min(a.min, a.ticks[0]), math.Max(a.max, a.ticks[len(a.ticks)-1])
+	}
+}
+
+// limit overrides the range of the axis with the values of the attributes `<name>min` and `<name>max`, unless they are empty.
+func (a *chartAxis) limit(name string, min string, max string) {
+	changed := false
+	if v, err := strconv.ParseFloat(min, 64); min != "" && err == nil && (v > 0 || !a.log) {
+		a.min, changed = v, true
+	} else if min != "" {
+		log.Printf("Malformed %vmin in chart data: %v", name, min)
+	}
+	if v, err := strconv.ParseFloat(max, 64); max != "" && err == nil {
+		a.max, changed = v, true
+	} else if max != "" {
+		log.Printf("Malformed %vmax in chart data: %v", name, max)
+	}
+	if a.max <= a.min {
+		a.max = a.min + 1
+	}
+	if changed {
+		a.update(false)
+	}
+}
+
+// frac returns the position of `v` on the axis, from 0 at the minimum to 1 at the maximum.
+func (a *chartAxis) frac(v float64) float64 {
+	if a.log {
+		v = math.Max(v, a.min)
+		return (math.Log10(v) - math.Log10(a.min)) / (math.Log10(a.max) - math.Log10(a.min))
+	}
+	return (v - a.min) / (a.max - a.min)
+}
+
+// inside returns true if `v` lies within the range of the axis.
+func (a *chartAxis) inside(v float64) bool {
+	return v >= a.min-1e-9*math.Abs(a.max-a.min) && v <= a.max+1e-9*math.Abs(a.max-a.min)
+}
+
+// label returns the tick label of the value `v`, formatted as specified by the axis and followed by its unit.
+func (a *chartAxis) label(v float64) string {
+	var str string
+	switch {
+	case a.time:
+		layout := a.format
+		if layout == "" {
+			layout = timeLayout(a.step, a.max-a.min)
+		}
+		str = time.Unix(int64(math.Round(v)), 0).UTC().Format(layout)
+	case a.format == "si":
+		str = formatSI(v)
+	case a.format == "thousands":
+		str = formatThousands(a.number(v))
+	case strings.Contains(a.format, "%"):
+		if strings.ContainsAny(a.format, "dxXo") {
+			str = fmt.Sprintf(a.format, int64(math.Round(v)))
+		} else {
+			str = fmt.Sprintf(a.format, v)
+		}
+	default:
+		str = a.number(v)
+	}
+	if a.unit == "" {
+		return str
+	}
+	// Units like "ms" are separated by a space, signs like "%" or "€" are not
+	if r := []rune(a.unit)[0]; unicode.IsLetter(r) {
+		return str + " " + a.unit
+	}
+	return str + a.unit
+}
+
+// number formats `v` with as many decimals as the ticks of the axis require.
+func (a *chartAxis) number(v float64) string {
+	if a.log {
+		return formatFloat(v)
+	}
+	return formatTick(v, a.step)
+}
+
+// labelWidth estimates the width of the widest tick label on the canvas.
+func (a *chartAxis) labelWidth() float64 {
+	maxLen := 0
+	for _, t := range a.ticks {
+		if n := len([]rune(a.label(t))); a.inside(t) && n > maxLen {
+			maxLen = n
+		}
+	}
+	return float64(maxLen) * 7
+}
+
+// chartAxes returns the x- and y-axis of a chart as specified by the attributes of the chart.
+func chartAxes(data *chartData, xmin, xmax float64, timeScale bool, ymin, ymax float64) (x, y *chartAxis) {
+	x = newChartAxis(xmin, xmax, data.xlog, timeScale, false)
+	x.setup("x", data.xformat, data.xunit, data.xtitle, data.xref)
+	y = newChartAxis(ymin, ymax, data.ylog, false, true)
+	y.limit("y", data.ymin, data.ymax)
+	y.setup("y", data.yformat, data.yunit, data.ytitle, data.yref)
+	return x, y
+}
+
+// secondaryAxis returns the secondary y-axis of a chart, which shows the series listed in the attribute `y2`.
+func secondaryAxis(data *chartData, ymin, ymax float64) *chartAxis {
+	y2 := newChartAxis(ymin, ymax, data.y2log, false, true)
+	y2.limit("y2", data.y2min, data.y2max)
+	y2.setup("y2", data.y2format, data.y2unit, data.y2title, data.y2ref)
+	return y2
+}
+
+// setup assigns the format, unit, title and reference lines of the axis `name`.
+func (a *chartAxis) setup(name string, format string, unit string, title string, refs string) {
+	if format != "" && !a.time && format != "si" && format != "thousands" && !strings.Contains(format, "%") {
+		log.Printf("Malformed %vformat in chart data: %v. Expected 'si', 'thousands' or a format like '%%.1f'", name, format)
+		format = ""
+	}
+	a.format, a.unit, a.title = format, unit, title
+	if refs == "" {
+		return
+	}
+	for _, part := range strings.Split(refs, ",") {
+		ref := chartRef{}
+		if pos := strings.Index(part, "="); pos != -1 {
+			ref.label = strings.TrimSpace(part[pos+1:])
+			part = part[:pos]
+		}
+		from, to := part, part
+		if pos := strings.Index(part, ".."); pos != -1 {
+			from, to = part[:pos], part[pos+2:]
+		}
+		var ok1, ok2 bool
+		ref.from, ok1 = a.parseValue(from)
+		ref.to, ok2 = a.parseValue(to)
+		if !ok1 || !ok2 {
+			log.Printf("Malformed %vref in chart data: %v. Expected values like '50=Target' or '40..60'", name, part)
+			continue
+		}
+		a.refs = append(a.refs, ref)
+	}
+}
+
+// parseValue parses a number or, on a time axis, a date.
+func (a *chartAxis) parseValue(s string) (float64, bool) {
+	s = strings.TrimSpace(s)
+	if v, err := strconv.ParseFloat(s, 64); err == nil {
+		return v, true
+	}
+	if a.time {
+		if t, ok := parseChartTime(s); ok {
+			return float64(t.Unix()), true
+		}
+	}
+	return 0, false
+}
+
+// chartTimeLayouts are the accepted formats of dates in chart data. Dates without a time zone are UTC.
+var chartTimeLayouts = []string{"2006-01-02", "2006-01", "2006-01-02T15:04", "2006-01-02T15:04:05", "2006-01-02 15:04", "2006-01-02 15:04:05", time.RFC3339}
+
+// parseChartTime parses an ISO 8601 date like "2024-03-01" or "2024-03-01T12:30:00Z".
+func parseChartTime(s string) (time.Time, bool) {
+	for _, layout := range chartTimeLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.UTC); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// timeSteps are the steps between the ticks of a time axis in seconds, up to two weeks. Larger steps are whole months or years.
+var timeSteps = []float64{1, 2, 5, 10, 15, 30, 60, 2 * 60, 5 * 60, 10 * 60, 15 * 60, 30 * 60,
+	3600, 2 * 3600, 3 * 3600, 6 * 3600, 12 * 3600, 86400, 2 * 86400, 7 * 86400, 14 * 86400}
+
+const (
+	secondsPerDay   = 86400
+	secondsPerMonth = 30.44 * secondsPerDay
+	secondsPerYear  = 365.25 * secondsPerDay
+)
+
+// timeTicks returns ticks at calendar boundaries, e.g. at full hours, on Mondays or on the first of a month.
+func timeTicks(min, max float64) (ticks []float64, step float64) {
+	raw := (max - min) / 5
+	for _, s := range timeSteps {
+		if s < raw {
+			continue
+		}
+		// Weeks start on Monday, while 1970-01-01 was a Thursday
+		shift := 0.0
+		if s >= 7*secondsPerDay {
+			shift = 4 * secondsPerDay
+		}
+		for t := math.Ceil((min-shift)/s)*s + shift; t <= max; t += s {
+			ticks = append(ticks, t)
+		}
+		return ticks, s
+	}
+	start := time.Unix(int64(min), 0).UTC()
+	if raw <= 6*secondsPerMonth {
+		months := 6
+		for _, m := range []int{1, 2, 3, 6} {
+			if float64(m)*secondsPerMonth >= raw {
+				months = m
+				break
+			}
+		}
+		month := (int(start.Month())-1)/months*months + 1
+		for t := time.Date(start.Year(), time.Month(month), 1, 0, 0, 0, 0, time.UTC); float64(t.Unix()) <= max; t = t.AddDate(0, months, 0) {
+			if float64(t.Unix()) >= min {
+				ticks = append(ticks, float64(t.Unix()))
+			}
+		}
+		return ticks, float64(months) * secondsPerMonth
+	}
+	end := time.Unix(int64(max), 0).UTC()
+	_, years := niceTicks(float64(start.Year()), float64(end.Year()+1))
+	years = math.Max(1, math.Round(years))
+	for y := math.Ceil(float64(start.Year())/years) * years; y <= float64(end.Year()); y += years {
+		t := time.Date(int(y), 1, 1, 0, 0, 0, 0, time.UTC)
+		if float64(t.Unix()) >= min {
+			ticks = append(ticks, float64(t.Unix()))
+		}
+	}
+	return ticks, years * secondsPerYear
+}
+
+// timeLayout returns the layout of the tick labels of a time axis, depending on the step between the ticks and the range of the axis.
+func timeLayout(step float64, span float64) string {
+	switch {
+	case step < 60:
+		return "15:04:05"
+	case step < secondsPerDay && span > secondsPerDay:
+		return "Jan 2 15:04"
+	case step < secondsPerDay:
+		return "15:04"
+	case step < 28*secondsPerDay:
+		return "Jan 2"
+	case step < secondsPerYear:
+		return "Jan 2006"
+	}
+	return "2006"
+}
+
+// logTicks returns ticks at the powers of ten from the one below `min` to the one above `max`.
+// If the range spans less than three decades, ticks at two and five times the powers of ten are added.
+func logTicks(min, max float64) (ticks []float64, step float64) {
+	first, last := math.Floor(math.Log10(min)+1e-9), math.Ceil(math.Log10(max)-1e-9)
+	factors := []float64{1}
+	if last-first < 3 {
+		factors = []float64{1, 2, 5}
+	}
+	for e := first; e <= last; e++ {
+		for _, f := range factors {
+			t := f * math.Pow(10, e)
+			if f == 1 || (t >= min*(1-1e-9) && t <= max*(1+1e-9)) {
+				ticks = append(ticks, t)
+			}
+		}
+	}
+	return ticks, 10
+}
+
+// formatSI formats a number with an SI prefix, e.g. 1500 as "1.5k".
+func formatSI(v float64) string {
+	if v == 0 {
+		return "0"
+	}
+	prefixes := map[int]string{-12: "p", -9: "n", -6: "µ", -3: "m", 0: "", 3: "k", 6: "M", 9: "G", 12: "T"}
+	e := int(math.Floor(math.Log10(math.Abs(v))/3)) * 3
+	e = int(math.Max(-12, math.Min(12, float64(e))))
+	scaled := math.Round(v/math.Pow(10, float64(e))*100) / 100
+	return formatFloat(scaled) + prefixes[e]
+}
+
+// formatThousands separates groups of three digits in the integer part of a formatted number by commas.
+func formatThousands(s string) string {
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+	frac := ""
+	if pos := strings.Index(s, "."); pos != -1 {
+		s, frac = s[:pos], s[pos:]
+	}
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	return sign + s + frac
+}
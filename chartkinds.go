@@ -0,0 +1,234 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// chartPoint is a value of a data series.
+// If the series are stacked, `base` is the sum of the series below and included in `y`.
+// `err` is the size of the error bar or zero.
+type chartPoint struct {
+	x, y, base, err float64
+}
+
+// chartSeries is a data series of a chart, i.e. the values of a Y column.
+type chartSeries struct {
+	name   string
+	color  string
+	points []chartPoint
+	// True if the series is shown on the secondary y-axis
+	secondary bool
+}
+
+// title returns the tooltip of a data point.
+func (s *chartSeries) title(p chartPoint) string {
+	str := s.name + ": " + formatFloat(p.y-p.base)
+	if p.err > 0 {
+		str += " ± " + formatFloat(p.err)
+	}
+	return str
+}
+
+// collectSeries returns the data series of all Y columns. Without an X column, the rows are numbered from 1.
+func collectSeries(data *chartData, colX *chartDataColumn) []*chartSeries {
+	var series []*chartSeries
+	stacked := make(map[float64]float64)
+	for _, colY := range data.columns {
+		if colY.kind != columnY {
+			continue
+		}
+		s := &chartSeries{name: colY.name, color: chartColor(data, len(series))}
+		for j, y := range colY.data {
+			x := float64(j + 1)
+			if colX != nil {
+				if j >= len(colX.data) || colX.data[j] == nil {
+					continue
+				}
+				x = colX.data[j].(float64)
+			}
+			if y == nil {
+				continue
+			}
+			p := chartPoint{x: x, y: y.(float64)}
+			if colY.errors != nil && j < len(colY.errors.data) && colY.errors.data[j] != nil {
+				p.err = math.Abs(colY.errors.data[j].(float64))
+			}
+			if data.stack || data.area {
+				p.base = stacked[x]
+				p.y += p.base
+				stacked[x] = p.y
+			}
+			s.points = append(s.points, p)
+		}
+		if colX != nil && len(colY.data) < len(colX.data) {
+			log.Printf("Too few values in the y-axis")
+		}
+		series = append(series, s)
+	}
+	return series
+}
+
+// columnValues returns the numbers of a column, skipping empty cells.
+func columnValues(c *chartDataColumn) []float64 {
+	var values []float64
+	for _, v := range c.data {
+		if v != nil {
+			values = append(values, v.(float64))
+		}
+	}
+	return values
+}
+
+// histogramSeries counts the values of the first Y column in bins of equal width.
+// The number of bins is given by the attribute `bins` or, by default, determined by Sturges' rule.
+// It returns a series with one point per bin, located at the center of the bin, and the width of the bins.
+func histogramSeries(data *chartData) (*chartSeries, float64) {
+	var colY *chartDataColumn
+	for _, c := range data.columns {
+		if c.kind == columnY {
+			colY = c
+			break
+		}
+	}
+	if colY == nil {
+		log.Printf("Missing Y column in histogram data")
+		return nil, 0
+	}
+	values := columnValues(colY)
+	if len(values) == 0 {
+		return &chartSeries{name: colY.name, color: chartColor(data, 0)}, 1
+	}
+	bins := int(math.Ceil(math.Log2(float64(len(values))))) + 1
+	if data.bins != "" {
+		n, err := strconv.Atoi(data.bins)
+		if err != nil || n < 1 {
+			log.Printf("Malformed number of bins in chart data: %v", data.bins)
+		} else {
+			bins = n
+		}
+	}
+	min, max := values[0], values[0]
+	for _, v := range values {
+		min, max = math.Min(min, v), math.Max(max, v)
+	}
+	width := (max - min) / float64(bins)
+	if width == 0 {
+		width = 1
+	}
+	counts := make([]int, bins)
+	for _, v := range values {
+		// The last bin includes its upper bound
+		b := int((v - min) / width)
+		if b >= bins {
+			b = bins - 1
+		}
+		counts[b]++
+	}
+	s := &chartSeries{name: colY.name, color: chartColor(data, 0)}
+	for b, n := range counts {
+		s.points = append(s.points, chartPoint{x: min + (float64(b)+0.5)*width, y: float64(n)})
+	}
+	return s, width
+}
+
+// regression returns the least squares fit y = a + b*x of the series.
+// The result is not ok if the series has less than two distinct x values.
+func (s *chartSeries) regression() (a, b float64, ok bool) {
+	n := float64(len(s.points))
+	var sx, sy, sxx, sxy float64
+	for _, p := range s.points {
+		sx += p.x
+		sy += p.y
+		sxx += p.x * p.x
+		sxy += p.x * p.y
+	}
+	d := n*sxx - sx*sx
+	if n < 2 || math.Abs(d) < 1e-12 {
+		return 0, 0, false
+	}
+	b = (n*sxy - sx*sy) / d
+	a = (sy - b*sx) / n
+	return a, b, true
+}
+
+// quantile returns the q-quantile of sorted values, interpolating linearly between neighbouring values.
+func quantile(sorted []float64, q float64) float64 {
+	pos := q * float64(len(sorted)-1)
+	i := int(math.Floor(pos))
+	if i+1 >= len(sorted) {
+		return sorted[len(sorted)-1]
+	}
+	return sorted[i] + (pos-float64(i))*(sorted[i+1]-sorted[i])
+}
+
+// svgBoxPlot renders one box per Y column, showing the quartiles of its values.
+// The whiskers extend to the most extreme values within 1.5 times the interquartile range. Values beyond are drawn as points.
+func svgBoxPlot(table *DocumentNode, data *chartData) string {
+	var series []*chartSeries
+	var values [][]float64
+	var categories []chartTick
+	ymin, ymax := math.Inf(1), math.Inf(-1)
+	for _, c := range data.columns {
+		if c.kind != columnY {
+			continue
+		}
+		v := columnValues(c)
+		if len(v) == 0 {
+			continue
+		}
+		sort.Float64s(v)
+		ymin, ymax = math.Min(ymin, v[0]), math.Max(ymax, v[len(v)-1])
+		series = append(series, &chartSeries{name: c.name, color: chartColor(data, len(series))})
+		values = append(values, v)
+		categories = append(categories, chartTick{float64(len(series)), c.name})
+	}
+	if len(series) == 0 {
+		log.Printf("Missing Y column in box plot data")
+		return ""
+	}
+	x, y := chartAxes(data, 0.5, float64(len(series))+0.5, false, ymin, ymax)
+	pl := newChartPlot(data, x, y, nil, categories)
+	result := svgChartOpen(table, "chart", chartWidth, chartHeight)
+	result += pl.axes(categories)
+	for i, s := range series {
+		v := values[i]
+		x := float64(i + 1)
+		q1, median, q3 := quantile(v, 0.25), quantile(v, 0.5), quantile(v, 0.75)
+		iqr := q3 - q1
+		lo, hi := q3, q1
+		for _, f := range v {
+			if f >= q1-1.5*iqr {
+				lo = math.Min(lo, f)
+			}
+			if f <= q3+1.5*iqr {
+				hi = math.Max(hi, f)
+			}
+		}
+		result += `<g class="series">`
+		result += pl.errorBar(x, lo, hi)
+		bx, by, bw, bh := pl.rect(x-0.25, q1, x+0.25, q3)
+		title := fmt.Sprintf("%v: median %v, quartiles %v – %v", s.name, formatFloat(median), formatFloat(q1), formatFloat(q3))
+		result += fmt.Sprintf(`<rect x="%.2f" y="%.2f" width="%.2f" height="%.2f" fill="%v" fill-opacity="0.8" stroke="#333"><title>%v</title></rect>`,
+			bx, by, bw, bh, s.color, html.EscapeString(title))
+		mx0, my0 := pl.pt(x-0.25, median)
+		mx1, my1 := pl.pt(x+0.25, median)
+		result += fmt.Sprintf(`<line x1="%.2f" y1="%.2f" x2="%.2f" y2="%.2f" stroke="#333" stroke-width="2"/>`, mx0, my0, mx1, my1)
+		for _, f := range v {
+			if f < lo || f > hi {
+				cx, cy := pl.pt(x, f)
+				result += fmt.Sprintf(`<circle cx="%.2f" cy="%.2f" r="3" fill="none" stroke="#333"><title>%v</title></circle>`, cx, cy, formatFloat(f))
+			}
+		}
+		result += `</g>`
+	}
+	return result + `</svg></div>`
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
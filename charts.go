@@ -13,6 +13,7 @@ const (
 	columnY
 	columnLabel
 	columnColor
+	columnError
 )
 
 type chartData struct {
@@ -28,12 +29,46 @@ type chartData struct {
 	fill      bool
 	ymin      string
 	ymax      string
+	// Chart types rendered as SVG only
+	scatter    bool
+	trend      bool
+	histogram  bool
+	bins       string
+	horizontal bool
+	grouped    bool
+	area       bool
+	boxplot    bool
+	// Formatting of the axes, rendered as SVG only
+	xtitle   string
+	ytitle   string
+	y2title  string
+	xunit    string
+	yunit    string
+	y2unit   string
+	xformat  string
+	yformat  string
+	y2format string
+	xlog     bool
+	ylog     bool
+	y2log    bool
+	y2min    string
+	y2max    string
+	// Names of the series shown on the secondary y-axis
+	y2series map[string]bool
+	// Reference lines and bands
+	xref  string
+	yref  string
+	y2ref string
 }
 
 type chartDataColumn struct {
 	kind int
 	name string
 	data []interface{}
+	// The Error column following a Y column, or nil
+	errors *chartDataColumn
+	// True if the X column holds dates, which are stored as seconds since 1970
+	time bool
 }
 
 func extractData(table *DocumentNode) *chartData {
@@ -49,6 +84,37 @@ func extractData(table *DocumentNode) *chartData {
 	result.text = table.Attributes["text"]
 	result.ymin = table.Attributes["ymin"]
 	result.ymax = table.Attributes["ymax"]
+	result.scatter = table.HasClass("scatter")
+	result.trend = table.HasClass("trend")
+	result.histogram = table.HasClass("histogram")
+	result.bins = table.Attributes["bins"]
+	result.horizontal = table.HasClass("horizontal")
+	result.grouped = table.HasClass("grouped")
+	result.area = table.HasClass("area")
+	result.boxplot = table.HasClass("boxplot")
+	result.xtitle = unescapeEntityValue(table.Attributes["xtitle"])
+	result.ytitle = unescapeEntityValue(table.Attributes["ytitle"])
+	result.y2title = unescapeEntityValue(table.Attributes["y2title"])
+	result.xunit = unescapeEntityValue(table.Attributes["xunit"])
+	result.yunit = unescapeEntityValue(table.Attributes["yunit"])
+	result.y2unit = unescapeEntityValue(table.Attributes["y2unit"])
+	result.xformat = unescapeEntityValue(table.Attributes["xformat"])
+	result.yformat = unescapeEntityValue(table.Attributes["yformat"])
+	result.y2format = unescapeEntityValue(table.Attributes["y2format"])
+	result.xlog = table.HasClass("xlog")
+	result.ylog = table.HasClass("ylog")
+	result.y2log = table.HasClass("y2log")
+	result.y2min = table.Attributes["y2min"]
+	result.y2max = table.Attributes["y2max"]
+	result.y2series = make(map[string]bool)
+	if y2, ok := table.Attributes["y2"]; ok {
+		for _, name := range strings.Split(unescapeEntityValue(y2), ",") {
+			result.y2series[strings.TrimSpace(name)] = true
+		}
+	}
+	result.xref = unescapeEntityValue(table.Attributes["xref"])
+	result.yref = unescapeEntityValue(table.Attributes["yref"])
+	result.y2ref = unescapeEntityValue(table.Attributes["y2ref"])
 
 	cols, _ := table.Columns()
 	for _, c := range cols {
@@ -57,20 +123,32 @@ func extractData(table *DocumentNode) *chartData {
 		cdata.name = t
 		if t == "X" {
 			cdata.kind = columnX
-		} else if t[0] == 'Y' {
+		} else if strings.HasPrefix(t, "Y") {
 			cdata.kind = columnY
-			cdata.name = t[1:]
+			cdata.name = strings.TrimSpace(t[1:])
 		} else if t == "Label" {
 			cdata.kind = columnLabel
 		} else if t == "Color" {
 			cdata.kind = columnColor
+		} else if t == "Error" {
+			cdata.kind = columnError
 		}
 		result.columns = append(result.columns, cdata)
 	}
+	// An Error column holds the error bars of the Y column in front of it
+	for i, c := range result.columns {
+		if c.kind == columnError {
+			if i == 0 || result.columns[i-1].kind != columnY {
+				log.Printf("Error column does not follow a Y column")
+				continue
+			}
+			result.columns[i-1].errors = c
+		}
+	}
 
 	rows := table.Rows()
 	for _, row := range rows {
-		cells := row.DocumentNodes("tbody-cell")
+		cells := row.DocumentNodes("#tbody-cell")
 		for col, cell := range cells {
 			if col >= len(result.columns) {
 				break
@@ -79,10 +157,17 @@ func extractData(table *DocumentNode) *chartData {
 			c := result.columns[col]
 			var v interface{}
 			switch c.kind {
-			case columnX, columnY:
+			case columnX, columnY, columnError:
 				if t != "" {
 					var err error
 					v, err = strconv.ParseFloat(t, 64)
+					if err != nil && c.kind == columnX {
+						// X values may be dates
+						if tm, ok := parseChartTime(t); ok {
+							v, err = float64(tm.Unix()), nil
+							c.time = true
+						}
+					}
 					if err != nil {
 						log.Printf("Malformed value in chart data: %v", t)
 						v = float64(0)
@@ -104,8 +189,25 @@ func extractData(table *DocumentNode) *chartData {
 	return result
 }
 
-func generatePieChart(table *DocumentNode) (result string) {
-	data := extractData(table)
+// svgOnly returns true if the chart uses features which the JavaScript backend does not support.
+func (data *chartData) svgOnly() bool {
+	if data.scatter || data.trend || data.histogram || data.horizontal || data.grouped || data.area || data.boxplot {
+		return true
+	}
+	if data.xtitle != "" || data.ytitle != "" || data.y2title != "" || data.xunit != "" || data.yunit != "" || data.y2unit != "" ||
+		data.xformat != "" || data.yformat != "" || data.y2format != "" || data.xlog || data.ylog || data.y2log || len(data.y2series) > 0 ||
+		data.xref != "" || data.yref != "" || data.y2ref != "" {
+		return true
+	}
+	for _, c := range data.columns {
+		if c.errors != nil || c.time {
+			return true
+		}
+	}
+	return false
+}
+
+func generatePieChart(table *DocumentNode, data *chartData) (result string) {
 	result = `<div id="` + table.ForceID() + `" class="piechart ` + table.Class() + `"`
 	if table.Style() != "" {
 		result += ` style="` + table.Style() + `"`
@@ -165,8 +267,7 @@ func generatePieChart(table *DocumentNode) (result string) {
 	return
 }
 
-func generateLineBarChart(table *DocumentNode) (result string) {
-	data := extractData(table)
+func generateLineBarChart(table *DocumentNode, data *chartData) (result string) {
 	result = `<div id="` + table.ForceID() + `" class="chart ` + table.Class() + `"`
 	if table.Style() != "" {
 		result += ` style="` + table.Style() + `"`
@@ -0,0 +1,690 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// chartColors are the default colors of data series, the same as the ones used by Flot.
+var chartColors = []string{"#edc240", "#afd8f8", "#cb4b4b", "#4da74d", "#9440ed"}
+
+// Size of the SVG canvas of charts. The SVG scales to the width of the surrounding element.
+const (
+	chartWidth    = 600
+	chartHeight   = 300
+	pieSize       = 300
+	chartMarginL  = 50
+	chartMarginR  = 15
+	chartMarginT  = 15
+	chartMarginB  = 30
+	chartBarWidth = 0.8
+)
+
+// chart returns the HTML of a #pie or #chart node.
+// The chart is rendered as SVG if the node has the attribute `svg` or if site.yaml specifies "Charts: svg",
+// and by JavaScript otherwise. The attribute `js` enforces JavaScript, except for chart types which only the SVG renderer supports.
+func (gen *HTMLGenerator) chart(node *DocumentNode) string {
+	data := extractData(node)
+	svg := gen.page.SVGCharts
+	if node.HasClass("svg") || data.svgOnly() {
+		svg = true
+	} else if node.HasClass("js") {
+		svg = false
+	}
+	if node.Tag == "#pie" {
+		if svg {
+			return svgPieChart(node, data)
+		}
+		return generatePieChart(node, data)
+	}
+	if svg {
+		return svgLineBarChart(node, data)
+	}
+	return generateLineBarChart(node, data)
+}
+
+// chartColor returns the color of the i-th slice of a pie chart or the i-th data series.
+// The i-th row of a Color column in the chart data overrides the default color.
+func chartColor(data *chartData, i int) string {
+	for _, c := range data.columns {
+		if c.kind == columnColor && i < len(c.data) && c.data[i] != nil && c.data[i].(string) != "" {
+			return html.EscapeString(c.data[i].(string))
+		}
+	}
+	return chartColors[i%len(chartColors)]
+}
+
+// svgChartOpen returns the element wrapping the SVG of a chart, carrying the id, class and style of the node.
+func svgChartOpen(table *DocumentNode, class string, width int, height int) string {
+	result := `<div id="` + table.ForceID() + `" class="` + class + ` ` + table.Class() + `"`
+	if table.Style() != "" {
+		result += ` style="` + table.Style() + `"`
+	}
+	result += `>`
+	result += fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %v %v" width="100%%" role="img" font-family="sans-serif" font-size="12">`, width, height)
+	return result
+}
+
+// svgLegend returns a legend with a colored box and a label for each entry.
+// The position is one of "ne", "nw", "se" or "sw" as in Flot, and "ne" by default.
+func svgLegend(labels []string, colors []string, pos string, left, top, right, bottom float64) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	maxLen := 0
+	for _, l := range labels {
+		if n := len([]rune(l)); n > maxLen {
+			maxLen = n
+		}
+	}
+	w := float64(maxLen)*7 + 30
+	h := float64(len(labels))*18 + 8
+	x := right - w - 5
+	y := top + 5
+	if strings.HasSuffix(pos, "w") {
+		x = left + 5
+	}
+	if strings.HasPrefix(pos, "s") {
+		y = bottom - h - 5
+	}
+	result := fmt.Sprintf(`<g class="legend"><rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="#fff" fill-opacity="0.85" stroke="#ccc"/>`, x, y, w, h)
+	for i, l := range labels {
+		ly := y + 6 + float64(i)*18
+		result += fmt.Sprintf(`<rect x="%.1f" y="%.1f" width="12" height="12" fill="%v"/>`, x+6, ly, colors[i])
+		result += fmt.Sprintf(`<text x="%.1f" y="%.1f">%v</text>`, x+24, ly+10, html.EscapeString(l))
+	}
+	return result + `</g>`
+}
+
+// svgPieChart renders a pie or donut chart as SVG.
+func svgPieChart(table *DocumentNode, data *chartData) string {
+	var colY, colLabel *chartDataColumn
+	for _, c := range data.columns {
+		if c.kind == columnY {
+			colY = c
+		} else if c.kind == columnLabel {
+			colLabel = c
+		}
+	}
+	if colY == nil {
+		log.Printf("Missing Y column in pie chart data")
+		return ""
+	}
+	var values []float64
+	total := 0.0
+	for _, v := range colY.data {
+		f := 0.0
+		if v != nil {
+			f = math.Max(v.(float64), 0)
+		}
+		values = append(values, f)
+		total += f
+	}
+	label := func(i int) string {
+		if colLabel != nil && i < len(colLabel.data) {
+			return colLabel.data[i].(string)
+		}
+		return ""
+	}
+
+	cx, cy := float64(pieSize)/2, float64(pieSize)/2
+	r := float64(pieSize)/2 - 10
+	if data.text == "outside" {
+		r -= 40
+	}
+	inner := 0.0
+	if data.donut {
+		inner = r / 2
+	}
+	result := svgChartOpen(table, "piechart", pieSize, pieSize)
+	point := func(radius, angle float64) (float64, float64) {
+		return cx + radius*math.Sin(angle), cy - radius*math.Cos(angle)
+	}
+	angle := 0.0
+	var labels, colors []string
+	for i, v := range values {
+		color := chartColor(data, i)
+		labels = append(labels, label(i))
+		colors = append(colors, color)
+		if total == 0 || v == 0 {
+			continue
+		}
+		sweep := v / total * 2 * math.Pi
+		if v >= total {
+			// A single slice is a full circle, which cannot be drawn as an arc
+			result += fmt.Sprintf(`<circle cx="%.2f" cy="%.2f" r="%.2f" fill="%v"/>`, cx, cy, r, color)
+			if inner > 0 {
+				result += fmt.Sprintf(`<circle cx="%.2f" cy="%.2f" r="%.2f" fill="#fff"/>`, cx, cy, inner)
+			}
+		} else {
+			large := 0
+			if sweep > math.Pi {
+				large = 1
+			}
+			x0, y0 := point(r, angle)
+			x1, y1 := point(r, angle+sweep)
+			path := fmt.Sprintf("M%.2f,%.2f A%.2f,%.2f 0 %v 1 %.2f,%.2f", x0, y0, r, r, large, x1, y1)
+			if inner > 0 {
+				ix0, iy0 := point(inner, angle+sweep)
+				ix1, iy1 := point(inner, angle)
+				path += fmt.Sprintf(" L%.2f,%.2f A%.2f,%.2f 0 %v 0 %.2f,%.2f Z", ix0, iy0, inner, inner, large, ix1, iy1)
+			} else {
+				path += fmt.Sprintf(" L%.2f,%.2f Z", cx, cy)
+			}
+			result += fmt.Sprintf(`<path d="%v" fill="%v" stroke="#fff"><title>%v</title></path>`, path, color, html.EscapeString(label(i)))
+		}
+		mid := angle + sweep/2
+		percent := strconv.Itoa(int(math.Round(v / total * 100)))
+		if data.text == "inside" {
+			x, y := point(r*3/4, mid)
+			if inner > 0 {
+				x, y = point((r+inner)/2, mid)
+			}
+			result += fmt.Sprintf(`<text x="%.2f" y="%.2f" text-anchor="middle" fill="#fff"><tspan x="%.2f">%v</tspan><tspan x="%.2f" dy="1.2em">%v%%</tspan></text>`, x, y-4, x, html.EscapeString(label(i)), x, percent)
+		} else if data.text == "outside" {
+			x, y := point(r+10, mid)
+			anchor := "start"
+			if math.Sin(mid) < 0 {
+				anchor = "end"
+			}
+			result += fmt.Sprintf(`<text x="%.2f" y="%.2f" text-anchor="%v" dominant-baseline="middle">%v</text>`, x, y, anchor, html.EscapeString(label(i)))
+		}
+		angle += sweep
+	}
+	if data.legend || data.legendpos != "" {
+		result += svgLegend(labels, colors, data.legendpos, 0, 0, pieSize, pieSize)
+	}
+	return result + `</svg></div>`
+}
+
+// niceTicks returns evenly spaced tick values covering the range from `min` to `max`, using steps of 1, 2 or 5 times a power of ten.
+func niceTicks(min, max float64) (ticks []float64, step float64) {
+	if max <= min {
+		max = min + 1
+	}
+	raw := (max - min) / 5
+	mag := math.Pow(10, math.Floor(math.Log10(raw)))
+	step = mag
+	for _, f := range []float64{1, 2, 5, 10} {
+		if f*mag >= raw {
+			step = f * mag
+			break
+		}
+	}
+	first, last := math.Floor(min/step+1e-9), math.Ceil(max/step-1e-9)
+	for k := first; k <= last; k++ {
+		ticks = append(ticks, k*step)
+	}
+	return ticks, step
+}
+
+// formatTick formats a tick value with as many decimals as the tick step requires.
+func formatTick(v float64, step float64) string {
+	decimals := 0
+	if step < 1 {
+		decimals = int(math.Ceil(-math.Log10(step)))
+	}
+	return strconv.FormatFloat(v, 'f', decimals, 64)
+}
+
+// svgLineBarChart renders a line, bar, point, area or scatter chart or a histogram as SVG.
+func svgLineBarChart(table *DocumentNode, data *chartData) string {
+	if data.boxplot {
+		return svgBoxPlot(table, data)
+	}
+	var colX, colLabel *chartDataColumn
+	for _, c := range data.columns {
+		if c.kind == columnX {
+			colX = c
+		} else if c.kind == columnLabel {
+			colLabel = c
+		}
+	}
+	var series []*chartSeries
+	unit := chartBarWidth
+	if data.histogram {
+		s, width := histogramSeries(data)
+		if s == nil {
+			return ""
+		}
+		series = []*chartSeries{s}
+		unit = width
+	} else {
+		if colX == nil && colLabel == nil {
+			log.Printf("Missing X or Label column in chart data")
+			return ""
+		}
+		series = collectSeries(data, colX)
+	}
+	names := make(map[string]bool)
+	for _, s := range series {
+		s.secondary = data.y2series[s.name]
+		names[s.name] = true
+	}
+	for name := range data.y2series {
+		if !names[name] {
+			log.Printf("Unknown series '%v' in attribute y2 of chart data", name)
+		}
+	}
+	bars := data.bars || data.histogram
+	points := data.points || data.scatter
+	lines := data.lines || data.area || (!bars && !points)
+	fill := data.fill || data.area
+	timeScale := colX != nil && colX.time
+	// Bars on a numeric or time axis are as wide as the smallest distance between two x values permits
+	if bars && !data.histogram && colX != nil {
+		var xs []float64
+		for _, s := range series {
+			for _, p := range s.points {
+				xs = append(xs, p.x)
+			}
+		}
+		sort.Float64s(xs)
+		spacing := math.Inf(1)
+		for k := 1; k < len(xs); k++ {
+			if d := xs[k] - xs[k-1]; d > 0 {
+				spacing = math.Min(spacing, d)
+			}
+		}
+		if !math.IsInf(spacing, 1) {
+			unit = chartBarWidth * spacing
+		}
+	}
+	barWidth := unit
+	grouped := data.grouped && bars && !data.stack && len(series) > 1
+	if grouped {
+		barWidth /= float64(len(series))
+	}
+
+	// Determine the ranges of the axes. Zero cannot be shown on a logarithmic axis and does not count.
+	xmin, xmax := math.Inf(1), math.Inf(-1)
+	ymin, ymax := math.Inf(1), math.Inf(-1)
+	y2min, y2max := math.Inf(1), math.Inf(-1)
+	for _, s := range series {
+		logScale := data.ylog
+		if s.secondary {
+			logScale = data.y2log
+		}
+		for _, p := range s.points {
+			xmin, xmax = math.Min(xmin, p.x), math.Max(xmax, p.x)
+			lo, hi := math.Min(p.y-p.err, p.base), math.Max(p.y+p.err, p.base)
+			if logScale && lo <= 0 {
+				lo = p.y
+			}
+			if s.secondary {
+				y2min, y2max = math.Min(y2min, lo), math.Max(y2max, hi)
+			} else {
+				ymin, ymax = math.Min(ymin, lo), math.Max(ymax, hi)
+			}
+		}
+	}
+	if math.IsInf(xmin, 1) {
+		xmin, xmax = 0, 1
+	}
+	if math.IsInf(ymin, 1) {
+		ymin, ymax = 0, 1
+	}
+	if (bars || fill) && !data.ylog {
+		ymin, ymax = math.Min(ymin, 0), math.Max(ymax, 0)
+	}
+	if (bars || fill) && !data.y2log && !math.IsInf(y2min, 1) {
+		y2min, y2max = math.Min(y2min, 0), math.Max(y2max, 0)
+	}
+	if bars {
+		pad := unit/2 + barWidth/8
+		xmin, xmax = xmin-pad, xmax+pad
+	}
+	if xmin == xmax {
+		xmin, xmax = xmin-1, xmax+1
+	}
+
+	var categories []chartTick
+	if colLabel != nil && !data.histogram {
+		for j, l := range colLabel.data {
+			x := float64(j + 1)
+			if colX != nil {
+				if j >= len(colX.data) || colX.data[j] == nil {
+					continue
+				}
+				x = colX.data[j].(float64)
+			}
+			categories = append(categories, chartTick{x, l.(string)})
+		}
+	}
+	x, y := chartAxes(data, xmin, xmax, timeScale, ymin, ymax)
+	var y2 *chartAxis
+	if !math.IsInf(y2min, 1) {
+		y2 = secondaryAxis(data, y2min, y2max)
+	}
+	pl := newChartPlot(data, x, y, y2, categories)
+	result := svgChartOpen(table, "chart", chartWidth, chartHeight)
+	result += pl.axes(categories)
+
+	// Data series. Stacked series are drawn last to first, such that the top series does not hide the others.
+	order := make([]int, len(series))
+	for i := range order {
+		order[i] = i
+	}
+	if data.stack || data.area {
+		sort.Sort(sort.Reverse(sort.IntSlice(order)))
+	}
+	for _, i := range order {
+		s := series[i]
+		sp := pl
+		if s.secondary {
+			sp = pl.secondary()
+		}
+		offset := 0.0
+		if grouped {
+			offset = -unit/2 + (float64(i)+0.5)*barWidth
+		}
+		result += `<g class="series">`
+		if bars {
+			for _, p := range s.points {
+				x, y, w, h := sp.rect(p.x+offset-barWidth/2, p.base, p.x+offset+barWidth/2, p.y)
+				result += fmt.Sprintf(`<rect x="%.2f" y="%.2f" width="%.2f" height="%.2f" fill="%v" fill-opacity="0.8" stroke="%v"><title>%v</title></rect>`,
+					x, y, w, h, s.color, s.color, html.EscapeString(s.title(p)))
+			}
+		}
+		if lines && len(s.points) > 0 {
+			var path []string
+			for _, p := range s.points {
+				path = append(path, sp.coords(p.x, p.y))
+			}
+			if fill {
+				var area []string
+				area = append(area, path...)
+				for k := len(s.points) - 1; k >= 0; k-- {
+					area = append(area, sp.coords(s.points[k].x, s.points[k].base))
+				}
+				result += fmt.Sprintf(`<polygon points="%v" fill="%v" fill-opacity="0.4"/>`, strings.Join(area, " "), s.color)
+			}
+			result += fmt.Sprintf(`<polyline points="%v" fill="none" stroke="%v" stroke-width="2"/>`, strings.Join(path, " "), s.color)
+		}
+		if points {
+			for _, p := range s.points {
+				cx, cy := sp.pt(p.x+offset, p.y)
+				result += fmt.Sprintf(`<circle cx="%.2f" cy="%.2f" r="3" fill="#fff" stroke="%v" stroke-width="2"><title>%v</title></circle>`,
+					cx, cy, s.color, html.EscapeString(s.title(p)))
+			}
+		}
+		for _, p := range s.points {
+			if p.err > 0 {
+				result += sp.errorBar(p.x+offset, p.y-p.err, p.y+p.err)
+			}
+		}
+		if data.trend {
+			if a, b, ok := s.regression(); ok {
+				x0, y0 := sp.pt(x.min, a+b*x.min)
+				x1, y1 := sp.pt(x.max, a+b*x.max)
+				result += fmt.Sprintf(`<line x1="%.2f" y1="%.2f" x2="%.2f" y2="%.2f" stroke="%v" stroke-width="1.5" stroke-dasharray="6 4"/>`, x0, y0, x1, y1, s.color)
+			}
+		}
+		result += `</g>`
+	}
+	if data.legend || data.legendpos != "" {
+		var names, colors []string
+		for _, s := range series {
+			names = append(names, s.name)
+			colors = append(colors, s.color)
+		}
+		result += svgLegend(names, colors, data.legendpos, pl.left, pl.top, pl.right, pl.bottom)
+	}
+	return result + `</svg></div>`
+}
+
+// chartTick is a label on the category axis of a chart.
+type chartTick struct {
+	pos   float64
+	label string
+}
+
+// chartPlot maps data coordinates to the SVG canvas.
+// In horizontal charts, the x-axis runs from top to bottom and the y-axis from left to right.
+// The secondary y-axis `y2` is nil unless a series is shown on it.
+type chartPlot struct {
+	x, y, y2                 *chartAxis
+	left, right, top, bottom float64
+	horizontal               bool
+}
+
+// newChartPlot creates a plot for the given axes and leaves room for their labels and titles.
+func newChartPlot(data *chartData, x, y, y2 *chartAxis, categories []chartTick) *chartPlot {
+	pl := &chartPlot{x: x, y: y, y2: y2, horizontal: data.horizontal}
+	pl.left, pl.right = chartMarginL, chartWidth-chartMarginR
+	pl.top, pl.bottom = chartMarginT, chartHeight-chartMarginB
+	xWidth := x.labelWidth()
+	if len(categories) > 0 {
+		maxLen := 0
+		for _, c := range categories {
+			if n := len([]rune(c.label)); n > maxLen {
+				maxLen = n
+			}
+		}
+		xWidth = float64(maxLen) * 7
+	}
+	if pl.horizontal {
+		pl.left = math.Min(math.Max(pl.left, xWidth+12), chartWidth/3)
+		if y2 != nil {
+			pl.top += 15
+		}
+	} else {
+		pl.left = math.Min(math.Max(pl.left, y.labelWidth()+12), chartWidth/3)
+		if y2 != nil {
+			pl.right = chartWidth - math.Min(math.Max(chartMarginL, y2.labelWidth()+12), chartWidth/3)
+		}
+	}
+	for _, a := range []*chartAxis{x, y, y2} {
+		if a == nil || a.title == "" {
+			continue
+		}
+		switch pl.side(a) {
+		case "left":
+			pl.left += 16
+		case "right":
+			pl.right -= 16
+		case "top":
+			pl.top += 16
+		case "bottom":
+			pl.bottom -= 16
+		}
+	}
+	return pl
+}
+
+// secondary returns a copy of the plot which maps y values by the secondary y-axis.
+func (pl *chartPlot) secondary() *chartPlot {
+	sp := *pl
+	sp.y = pl.y2
+	return &sp
+}
+
+// side returns the side of the canvas which shows the labels of the axis `a`.
+func (pl *chartPlot) side(a *chartAxis) string {
+	switch {
+	case a == pl.x && pl.horizontal:
+		return "left"
+	case a == pl.x:
+		return "bottom"
+	case a == pl.y2 && pl.horizontal:
+		return "top"
+	case a == pl.y2:
+		return "right"
+	case pl.horizontal:
+		return "bottom"
+	}
+	return "left"
+}
+
+// pos returns the canvas coordinate of the value `v` on the axis `a`. Values outside of a y-axis are clipped.
+func (pl *chartPlot) pos(a *chartAxis, v float64) float64 {
+	f := a.frac(v)
+	if a != pl.x {
+		f = math.Max(0, math.Min(1, f))
+	}
+	switch side := pl.side(a); {
+	case side == "bottom" || side == "top":
+		return pl.left + f*(pl.right-pl.left)
+	case a == pl.x:
+		return pl.top + f*(pl.bottom-pl.top)
+	}
+	return pl.bottom - f*(pl.bottom-pl.top)
+}
+
+// pt returns the position of a data point on the canvas.
+func (pl *chartPlot) pt(x, y float64) (float64, float64) {
+	if pl.horizontal {
+		return pl.pos(pl.y, y), pl.pos(pl.x, x)
+	}
+	return pl.pos(pl.x, x), pl.pos(pl.y, y)
+}
+
+// coords returns the position of a data point as used by SVG polylines.
+func (pl *chartPlot) coords(x, y float64) string {
+	sx, sy := pl.pt(x, y)
+	return fmt.Sprintf("%.2f,%.2f", sx, sy)
+}
+
+// rect returns the position and size of the rectangle spanned by two data points.
+func (pl *chartPlot) rect(x0, y0, x1, y1 float64) (x, y, w, h float64) {
+	ax, ay := pl.pt(x0, y0)
+	bx, by := pl.pt(x1, y1)
+	return math.Min(ax, bx), math.Min(ay, by), math.Abs(bx - ax), math.Abs(by - ay)
+}
+
+// errorBar returns a line from y0 to y1 at x with caps at both ends.
+func (pl *chartPlot) errorBar(x, y0, y1 float64) string {
+	ax, ay := pl.pt(x, y0)
+	bx, by := pl.pt(x, y1)
+	dx, dy := 4.0, 0.0
+	if pl.horizontal {
+		dx, dy = 0, 4
+	}
+	return fmt.Sprintf(`<path d="M%.2f,%.2f L%.2f,%.2f M%.2f,%.2f L%.2f,%.2f M%.2f,%.2f L%.2f,%.2f" stroke="#333" fill="none"/>`,
+		ax, ay, bx, by, ax-dx, ay-dy, ax+dx, ay+dy, bx-dx, by-dy, bx+dx, by+dy)
+}
+
+// axes returns the grid, the reference lines, the frame, the tick labels and the titles of the plot.
+// The x-axis is labeled with `categories` or, if there are none, with the ticks of the axis.
+func (pl *chartPlot) axes(categories []chartTick) string {
+	axes := []*chartAxis{pl.x, pl.y}
+	if pl.y2 != nil {
+		axes = append(axes, pl.y2)
+	}
+	result := `<g class="axis" stroke="#ddd">`
+	for _, t := range pl.y.ticks {
+		if pl.y.inside(t) {
+			p := pl.pos(pl.y, t)
+			result += pl.line(pl.y, p)
+		}
+	}
+	result += `</g>`
+	for _, a := range axes {
+		result += pl.refs(a)
+	}
+	result += fmt.Sprintf(`<rect x="%.2f" y="%.2f" width="%.2f" height="%.2f" fill="none" stroke="#999"/>`, pl.left, pl.top, pl.right-pl.left, pl.bottom-pl.top)
+	result += `<g class="ticks" fill="#545454">`
+	for _, a := range axes[1:] {
+		for _, t := range a.ticks {
+			if a.inside(t) {
+				result += pl.label(a, pl.pos(a, t), a.label(t))
+			}
+		}
+	}
+	if len(categories) == 0 {
+		for _, t := range pl.x.ticks {
+			if pl.x.inside(t) {
+				categories = append(categories, chartTick{t, pl.x.label(t)})
+			}
+		}
+	}
+	for _, c := range categories {
+		result += pl.label(pl.x, pl.pos(pl.x, c.pos), c.label)
+	}
+	result += `</g>`
+	for _, a := range axes {
+		if a.title != "" {
+			result += pl.title(a)
+		}
+	}
+	return result
+}
+
+// line returns a line across the plot at the canvas coordinate `p` of the axis `a`.
+func (pl *chartPlot) line(a *chartAxis, p float64) string {
+	if side := pl.side(a); side == "bottom" || side == "top" {
+		return fmt.Sprintf(`<line x1="%.2f" y1="%.2f" x2="%.2f" y2="%.2f"/>`, p, pl.top, p, pl.bottom)
+	}
+	return fmt.Sprintf(`<line x1="%.2f" y1="%.2f" x2="%.2f" y2="%.2f"/>`, pl.left, p, pl.right, p)
+}
+
+// refs returns the reference lines and bands of the axis `a`. References outside of the axis are omitted.
+func (pl *chartPlot) refs(a *chartAxis) string {
+	if len(a.refs) == 0 {
+		return ""
+	}
+	vertical := pl.side(a) == "bottom" || pl.side(a) == "top"
+	result := `<g class="reference" stroke="#c00" fill="#c00">`
+	for _, r := range a.refs {
+		from, to := math.Min(r.from, r.to), math.Max(r.from, r.to)
+		if to < a.min || from > a.max {
+			continue
+		}
+		p0 := pl.pos(a, math.Max(from, a.min))
+		p1 := pl.pos(a, math.Min(to, a.max))
+		if from == to {
+			result += strings.Replace(pl.line(a, p0), "/>", ` stroke-dasharray="4 3"/>`, 1)
+		} else if vertical {
+			result += fmt.Sprintf(`<rect x="%.2f" y="%.2f" width="%.2f" height="%.2f" fill-opacity="0.1" stroke="none"/>`, math.Min(p0, p1), pl.top, math.Abs(p1-p0), pl.bottom-pl.top)
+		} else {
+			result += fmt.Sprintf(`<rect x="%.2f" y="%.2f" width="%.2f" height="%.2f" fill-opacity="0.1" stroke="none"/>`, pl.left, math.Min(p0, p1), pl.right-pl.left, math.Abs(p1-p0))
+		}
+		if r.label == "" {
+			continue
+		}
+		label := html.EscapeString(r.label)
+		if vertical && p0 > (pl.left+pl.right)/2 {
+			result += fmt.Sprintf(`<text x="%.2f" y="%.2f" text-anchor="end" stroke="none">%v</text>`, math.Max(p0, p1)-4, pl.top+14, label)
+		} else if vertical {
+			result += fmt.Sprintf(`<text x="%.2f" y="%.2f" stroke="none">%v</text>`, math.Min(p0, p1)+4, pl.top+14, label)
+		} else {
+			result += fmt.Sprintf(`<text x="%.2f" y="%.2f" text-anchor="end" stroke="none">%v</text>`, pl.right-4, math.Min(p0, p1)-4, label)
+		}
+	}
+	return result + `</g>`
+}
+
+// label returns a tick label at the canvas coordinate `p` of the axis `a`.
+func (pl *chartPlot) label(a *chartAxis, p float64, text string) string {
+	text = html.EscapeString(text)
+	switch pl.side(a) {
+	case "bottom":
+		return fmt.Sprintf(`<text x="%.2f" y="%.2f" text-anchor="middle">%v</text>`, p, pl.bottom+18, text)
+	case "top":
+		return fmt.Sprintf(`<text x="%.2f" y="%.2f" text-anchor="middle">%v</text>`, p, pl.top-6, text)
+	case "right":
+		return fmt.Sprintf(`<text x="%.2f" y="%.2f" dominant-baseline="middle">%v</text>`, pl.right+6, p, text)
+	}
+	return fmt.Sprintf(`<text x="%.2f" y="%.2f" text-anchor="end" dominant-baseline="middle">%v</text>`, pl.left-6, p, text)
+}
+
+// title returns the title of the axis `a`, placed outside of its tick labels.
+func (pl *chartPlot) title(a *chartAxis) string {
+	text := html.EscapeString(a.title)
+	switch pl.side(a) {
+	case "bottom":
+		return fmt.Sprintf(`<text x="%.2f" y="%.2f" text-anchor="middle" class="axis-title">%v</text>`, (pl.left+pl.right)/2, pl.bottom+36, text)
+	case "top":
+		return fmt.Sprintf(`<text x="%.2f" y="%.2f" text-anchor="middle" class="axis-title">%v</text>`, (pl.left+pl.right)/2, pl.top-24, text)
+	case "right":
+		x, y := float64(chartWidth-12), (pl.top+pl.bottom)/2
+		return fmt.Sprintf(`<text x="%.2f" y="%.2f" text-anchor="middle" transform="rotate(90 %.2f %.2f)" class="axis-title">%v</text>`, x, y, x, y, text)
+	}
+	x, y := 14.0, (pl.top+pl.bottom)/2
+	return fmt.Sprintf(`<text x="%.2f" y="%.2f" text-anchor="middle" transform="rotate(-90 %.2f %.2f)" class="axis-title">%v</text>`, x, y, x, y, text)
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// codeAnnotations are the presentational attributes of a #code node.
+//
+//	#code:go;linenos;hl:2-4,7;diff;callouts;title:main.go
+//
+// `linenos` numbers the lines, `linenos:10` starts counting at 10.
+// `hl` highlights lines, counted as they are numbered.
+// `diff` marks lines starting with "+" or "-" as added or removed.
+// `callouts` turns markers like "<1>" at the end of a line into links to the items of a list following the code block.
+// `title` is shown as a caption above the code block.
+type codeAnnotations struct {
+	lineNumbers bool
+	firstLine   int
+	marked      map[int]bool
+	diff        bool
+	callouts    bool
+	title       string
+}
+
+// calloutPattern matches a callout marker at the end of a line, including an optional comment sign in front of it.
+var calloutPattern = regexp.MustCompile(`[ \t]*(?:(?://|#|--|;)[ \t]*)?<([0-9]+)>[ \t]*$`)
+
+// parseCodeAnnotations removes the annotation attributes from a #code node and stores them in the node.
+func parseCodeAnnotations(node *DocumentNode) error {
+	a := &codeAnnotations{firstLine: 1}
+	found := false
+	if v, ok := node.Attributes["linenos"]; ok {
+		a.lineNumbers = true
+		if v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return fmt.Errorf("Malformed line number '%v' in attribute linenos", v)
+			}
+			a.firstLine = n
+		}
+		found = true
+	}
+	if v, ok := node.Attributes["hl"]; ok {
+		marked, err := parseLineSelection(v)
+		if err != nil {
+			return err
+		}
+		a.marked = marked
+		found = true
+	}
+	if _, ok := node.Attributes["diff"]; ok {
+		a.diff = true
+		found = true
+	}
+	if _, ok := node.Attributes["callouts"]; ok {
+		a.callouts = true
+		found = true
+	}
+	if v, ok := node.Attributes["title"]; ok {
+		a.title = unescapeEntityValue(v)
+		found = true
+	}
+	for _, k := range []string{"linenos", "hl", "diff", "callouts", "title"} {
+		delete(node.Attributes, k)
+	}
+	if found {
+		node.code = a
+	}
+	return nil
+}
+
+// parseLineSelection parses a comma separated list of lines and line ranges such as "2-4,7".
+func parseLineSelection(spec string) (map[int]bool, error) {
+	result := make(map[int]bool)
+	for _, part := range strings.Split(spec, ",") {
+		f, t := part, part
+		if pos := strings.Index(part, "-"); pos != -1 {
+			f, t = part[:pos], part[pos+1:]
+		}
+		from, err1 := strconv.Atoi(strings.TrimSpace(f))
+		to, err2 := strconv.Atoi(strings.TrimSpace(t))
+		if err1 != nil || err2 != nil || from > to {
+			return nil, fmt.Errorf("Malformed line selection '%v'. Expected lines like '2-4,7'", spec)
+		}
+		for i := from; i <= to; i++ {
+			result[i] = true
+		}
+	}
+	return result, nil
+}
+
+// calloutTargets returns the ids of the items of the list following the #code node `node`.
+// The n-th item explains the callout marker "<n>". Items without a label are assigned an id.
+func calloutTargets(node *DocumentNode) []string {
+	list := node.NextSibling
+	if list == nil || (list.Tag != "#ol" && list.Tag != "#ul") {
+		return nil
+	}
+	var ids []string
+	for _, item := range list.Children {
+		if item.Tag != "#li" {
+			continue
+		}
+		if item.ID() == "" {
+			item.forcedID = item.ids().unique(fmt.Sprintf("callout-%v", len(ids)+1))
+		}
+		ids = append(ids, item.ID())
+	}
+	return ids
+}
+
+// annotatedCode returns the HTML of the code of a #code node with annotations.
+// Every line is wrapped in a span, which carries the line number, the highlighting and the callout marker of the line.
+func (gen *HTMLGenerator) annotatedCode(node *DocumentNode, code string) string {
+	a := node.code
+	// Trailing line breaks separate the code from the following text and do not count as lines
+	trimmed := strings.TrimRight(code, "\n")
+	trailing := code[len(trimmed):]
+	code = trimmed
+	lines := strings.Split(code, "\n")
+	callouts := make([]int, len(lines))
+	if a.callouts {
+		for i, l := range lines {
+			if m := calloutPattern.FindStringSubmatchIndex(l); m != nil {
+				callouts[i], _ = strconv.Atoi(l[m[2]:m[3]])
+				lines[i] = l[:m[0]]
+			}
+		}
+	}
+	var htmlLines []string
+	if lexer := codeLexer(node); lexer != nil {
+		htmlLines = gen.highlightLines(lexer, strings.Join(lines, "\n"))
+	} else {
+		for _, l := range lines {
+			htmlLines = append(htmlLines, html.EscapeString(l))
+		}
+	}
+	var targets []string
+	if a.callouts {
+		targets = calloutTargets(node)
+	}
+	width := len(strconv.Itoa(a.firstLine + len(lines) - 1))
+	for i, l := range htmlLines {
+		number := a.firstLine + i
+		var kinds []string
+		if a.marked[number] {
+			kinds = append(kinds, "line-mark")
+		}
+		if a.diff && strings.HasPrefix(lines[i], "+") {
+			kinds = append(kinds, "line-add")
+		} else if a.diff && strings.HasPrefix(lines[i], "-") {
+			kinds = append(kinds, "line-del")
+		}
+		if a.lineNumbers {
+			l = gen.tokenSpan("lineno", fmt.Sprintf("%*d ", width, number)) + l
+		}
+		if n := callouts[i]; n > 0 {
+			marker := strconv.Itoa(n)
+			if n <= len(targets) {
+				l += ` <a href="#` + html.EscapeString(targets[n-1]) + `">` + gen.tokenSpan("callout", marker) + `</a>`
+			} else {
+				l += " " + gen.tokenSpan("callout", marker)
+			}
+		}
+		if len(kinds) > 0 {
+			l = gen.tokenSpan(strings.Join(kinds, " "), l)
+		}
+		htmlLines[i] = l
+	}
+	return strings.Join(htmlLines, "\n") + trailing
+}
+
+// CodeTitle returns the escaped caption of a #code node as specified by its attribute `title`.
+func (ctx *NodeContext) CodeTitle() string {
+	if ctx.node == nil || ctx.node.code == nil {
+		return ""
+	}
+	return html.EscapeString(ctx.node.code.title)
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// SourceResolver returns the content of the source file at the slash-separated path `fname`.
+// A path starting with a slash is relative to the site directory.
+// Otherwise it is relative to the content directory and may point outside of it, e.g. "../examples/main.go".
+type SourceResolver func(fname string) ([]byte, error)
+
+// codeLanguages maps file extensions to the language class of #code blocks.
+// Extensions not listed here are used as class without the leading dot.
+var codeLanguages = map[string]string{
+	".go":   "go",
+	".js":   "js",
+	".mjs":  "js",
+	".ts":   "ts",
+	".py":   "python",
+	".rb":   "ruby",
+	".rs":   "rust",
+	".java": "java",
+	".kt":   "kotlin",
+	".c":    "c",
+	".h":    "c",
+	".cc":   "cpp",
+	".cpp":  "cpp",
+	".hpp":  "cpp",
+	".cs":   "csharp",
+	".sh":   "bash",
+	".bash": "bash",
+	".yml":  "yaml",
+	".yaml": "yaml",
+	".json": "json",
+	".html": "html",
+	".htm":  "html",
+	".css":  "css",
+	".sql":  "sql",
+	".md":   "markdown",
+}
+
+// EnableSourceFiles allows #code blocks and tables to load their text from source files via the `src` attribute.
+func (parser *Parser) EnableSourceFiles(resolver SourceResolver) {
+	parser.sourceResolver = resolver
+}
+
+// loadCode appends the source file referenced by the `src` attribute of a #code node to the node.
+// The attribute `lines`, e.g. "10-42", "10-" or "7", selects a range of lines (counting from 1).
+// Alternatively, the attribute `region` selects the lines between a line containing "#region name"
+// and a line containing "#endregion". The marker lines are usually comments and not part of the result.
+// Unless the node has a class already, the language class is derived from the file extension.
+func (parser *Parser) loadCode(node *DocumentNode) error {
+	src := node.Attributes["src"]
+	lines, hasLines := node.Attributes["lines"]
+	region, hasRegion := node.Attributes["region"]
+	delete(node.Attributes, "src")
+	delete(node.Attributes, "lines")
+	delete(node.Attributes, "region")
+	if parser.sourceResolver == nil {
+		return errors.New("Loading source files is not supported here")
+	}
+	if hasLines && hasRegion {
+		return errors.New("The attributes lines and region cannot be combined")
+	}
+	fname := src
+	if !strings.HasPrefix(fname, "/") {
+		fname = path.Join(path.Dir(parser.fname), fname)
+	}
+	data, err := parser.sourceResolver(fname)
+	if err != nil {
+		return fmt.Errorf("Cannot load source file %v: %v", src, err)
+	}
+	text := strings.Replace(string(removeBOM(data)), "\r\n", "\n", -1)
+	all := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	var selected []string
+	if hasLines {
+		if selected, err = selectLines(all, lines); err != nil {
+			return fmt.Errorf("In %v: %v", src, err)
+		}
+		// Line numbers continue the numbering of the source file
+		if v, ok := node.Attributes["linenos"]; ok && v == "" {
+			node.Attributes["linenos"] = strings.TrimSpace(strings.SplitN(lines, "-", 2)[0])
+		}
+	} else if hasRegion {
+		if selected, err = selectRegion(all, region); err != nil {
+			return fmt.Errorf("In %v: %v", src, err)
+		}
+	} else {
+		selected = all
+	}
+
+	if node.Attributes["class"] == "" {
+		ext := strings.ToLower(path.Ext(fname))
+		lang, ok := codeLanguages[ext]
+		if !ok {
+			lang = strings.TrimPrefix(ext, ".")
+		}
+		if lang != "" {
+			node.Attributes["class"] = lang
+		}
+	}
+
+	node.AppendText(&CodeNode{Text: strings.Join(selected, "\n"), Parent: node})
+	return nil
+}
+
+// selectLines returns the lines in the range `spec`, e.g. "10-42", "10-" or "7".
+func selectLines(lines []string, spec string) ([]string, error) {
+	from, to := spec, spec
+	if pos := strings.Index(spec, "-"); pos != -1 {
+		from, to = spec[:pos], spec[pos+1:]
+	}
+	start, err := strconv.Atoi(strings.TrimSpace(from))
+	if err != nil {
+		return nil, fmt.Errorf("Malformed line range %v", spec)
+	}
+	end := len(lines)
+	if strings.TrimSpace(to) != "" {
+		if end, err = strconv.Atoi(strings.TrimSpace(to)); err != nil {
+			return nil, fmt.Errorf("Malformed line range %v", spec)
+		}
+	}
+	if start < 1 || end < start || end > len(lines) {
+		return nil, fmt.Errorf("Line range %v is outside of the file with %v lines", spec, len(lines))
+	}
+	return lines[start-1 : end], nil
+}
+
+// selectRegion returns the lines between the markers "#region name" and "#endregion".
+// Markers of nested regions are omitted from the result.
+func selectRegion(lines []string, name string) ([]string, error) {
+	var result []string
+	depth := 0
+	for _, l := range lines {
+		marker, markerName := regionMarker(l)
+		if depth == 0 {
+			if marker == "#region" && markerName == name {
+				depth = 1
+			}
+			continue
+		}
+		switch marker {
+		case "#region":
+			depth++
+		case "#endregion":
+			depth--
+			if depth == 0 {
+				return result, nil
+			}
+		default:
+			result = append(result, l)
+		}
+	}
+	if depth != 0 {
+		return nil, fmt.Errorf("Region %v is not terminated with #endregion", name)
+	}
+	return nil, fmt.Errorf("Unknown region %v", name)
+}
+
+// regionMarker returns "#region" or "#endregion" and the region name if the line is a region marker.
+func regionMarker(line string) (string, string) {
+	for _, m := range []string{"#endregion", "#region"} {
+		if pos := strings.Index(line, m); pos != -1 {
+			fields := strings.Fields(line[pos+len(m):])
+			if len(fields) > 0 && !strings.HasPrefix(fields[0], "*/") && !strings.HasPrefix(fields[0], "-->") {
+				return m, fields[0]
+			}
+			return m, ""
+		}
+	}
+	return "", ""
+}
@@ -0,0 +1,336 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// loadTable fills a #table, #chart, #pie or #timeline node with the data file referenced by its `src` attribute.
+//
+//	#chart;src:data/sales.csv;columns:month=X,revenue=Y\ Revenue;where:year=2024;sort:month
+//
+// CSV and TSV files start with a header line. JSON files hold an array of objects or an array of arrays,
+// whose first element is the header. The file is resolved like the `src` attribute of #code.
+// `columns` selects columns by name and optionally renames them, e.g. "revenue=Y Revenue".
+// `where` keeps the rows matching all of its comma separated conditions, e.g. "year=2024,revenue>1000".
+// The operators are =, !=, <, <=, >, >= and ~ for "contains". Numbers are compared as numbers, other values as strings.
+// `sort` orders the rows by the listed columns, descending if the name starts with "-". `limit` keeps the first rows only.
+// The rows are parsed into the same nodes as rows written in markdown.
+func (parser *Parser) loadTable(node *DocumentNode) error {
+	src := node.Attributes["src"]
+	attrs := make(map[string]string)
+	for _, k := range []string{"src", "columns", "where", "sort", "limit"} {
+		if v, ok := node.Attributes[k]; ok {
+			attrs[k] = unescapeEntityValue(v)
+			delete(node.Attributes, k)
+		}
+	}
+	if parser.sourceResolver == nil {
+		return errors.New("Loading data files is not supported here")
+	}
+	fname := src
+	if !strings.HasPrefix(fname, "/") {
+		fname = path.Join(path.Dir(parser.fname), fname)
+	}
+	data, err := parser.sourceResolver(fname)
+	if err != nil {
+		return fmt.Errorf("Cannot load data file %v: %v", src, err)
+	}
+	parser.addInclude(fname)
+
+	var header []string
+	var rows [][]string
+	switch ext := strings.ToLower(path.Ext(fname)); ext {
+	case ".csv", ".tsv":
+		r := csv.NewReader(bytes.NewReader(removeBOM(data)))
+		if ext == ".tsv" {
+			r.Comma = '\t'
+			r.LazyQuotes = true
+		}
+		r.FieldsPerRecord = -1
+		records, err := r.ReadAll()
+		if err != nil {
+			return fmt.Errorf("In %v: %v", src, err)
+		}
+		if len(records) > 0 {
+			header, rows = records[0], records[1:]
+		}
+	case ".json":
+		if header, rows, err = parseJSONTable(removeBOM(data)); err != nil {
+			return fmt.Errorf("In %v: %v", src, err)
+		}
+	default:
+		return fmt.Errorf("Unknown format of data file %v. Expected .csv, .tsv or .json", src)
+	}
+	if header, rows, err = selectTableData(header, rows, attrs); err != nil {
+		return fmt.Errorf("In %v: %v", src, err)
+	}
+
+	thead := parser.appendDataNode(node, "#thead")
+	row := parser.appendDataNode(thead, "#thead-row")
+	for _, h := range header {
+		cell := parser.appendDataNode(row, "#thead-cell")
+		cell.AppendText(&TextNode{Text: h, Parent: cell})
+	}
+	tbody := parser.appendDataNode(node, "#tbody")
+	for _, values := range rows {
+		row := parser.appendDataNode(tbody, "#tbody-row")
+		for i := range header {
+			cell := parser.appendDataNode(row, "#tbody-cell")
+			if i < len(values) {
+				cell.AppendText(&TextNode{Text: values[i], Parent: cell})
+			}
+		}
+	}
+	return nil
+}
+
+// appendDataNode appends a new node of the table loaded by `loadTable`.
+func (parser *Parser) appendDataNode(parent *DocumentNode, tag string) *DocumentNode {
+	child := parser.newDocumentNode(tag, parent.Indent)
+	child.Line = parent.Line
+	child.Colspan = 1
+	child.Parent = parent
+	if len(parent.Children) > 0 {
+		prev := parent.Children[len(parent.Children)-1]
+		prev.NextSibling = child
+		child.PrevSibling = prev
+	}
+	parent.Children = append(parent.Children, child)
+	return child
+}
+
+// parseJSONTable parses an array of arrays or objects. The columns of objects are ordered as their keys first appear.
+func parseJSONTable(data []byte) (header []string, rows [][]string, err error) {
+	var elements []json.RawMessage
+	if err := json.Unmarshal(data, &elements); err != nil {
+		return nil, nil, err
+	}
+	columns := make(map[string]int)
+	for _, e := range elements {
+		e = bytes.TrimSpace(e)
+		if len(e) > 0 && e[0] == '[' {
+			var values []interface{}
+			if err := json.Unmarshal(e, &values); err != nil {
+				return nil, nil, err
+			}
+			var row []string
+			for _, v := range values {
+				row = append(row, jsonCellText(v))
+			}
+			if header == nil {
+				header = row
+			} else {
+				rows = append(rows, row)
+			}
+			continue
+		}
+		// Decode objects token by token to preserve the order of the keys
+		dec := json.NewDecoder(bytes.NewReader(e))
+		if t, err := dec.Token(); err != nil || t != json.Delim('{') {
+			return nil, nil, errors.New("Expected an array of arrays or objects")
+		}
+		row := make([]string, len(header))
+		for dec.More() {
+			t, err := dec.Token()
+			if err != nil {
+				return nil, nil, err
+			}
+			key := t.(string)
+			var v interface{}
+			if err := dec.Decode(&v); err != nil {
+				return nil, nil, err
+			}
+			i, ok := columns[key]
+			if !ok {
+				i = len(header)
+				columns[key] = i
+				header = append(header, key)
+			}
+			for len(row) <= i {
+				row = append(row, "")
+			}
+			row[i] = jsonCellText(v)
+		}
+		rows = append(rows, row)
+	}
+	return header, rows, nil
+}
+
+// jsonCellText returns the text of a table cell holding a JSON value.
+func jsonCellText(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return formatFloat(v)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+// tableCondition is a condition of the attribute `where` of a data-backed table.
+type tableCondition struct {
+	column int
+	op     string
+	value  string
+}
+
+// selectTableData applies the attributes `columns`, `where`, `sort` and `limit` of a data-backed table.
+func selectTableData(header []string, rows [][]string, attrs map[string]string) ([]string, [][]string, error) {
+	index := func(name string) (int, error) {
+		for i, h := range header {
+			if h == name {
+				return i, nil
+			}
+		}
+		return 0, fmt.Errorf("Unknown column '%v'. The columns are %v", name, strings.Join(header, ", "))
+	}
+	cell := func(row []string, i int) string {
+		if i < len(row) {
+			return row[i]
+		}
+		return ""
+	}
+
+	if spec, ok := attrs["where"]; ok {
+		var conditions []tableCondition
+		for _, part := range strings.Split(spec, ",") {
+			c := tableCondition{}
+			pos := -1
+			for _, op := range []string{"!=", "<=", ">=", "=", "<", ">", "~"} {
+				if p := strings.Index(part, op); p > 0 && (pos == -1 || p < pos) {
+					pos, c.op = p, op
+				}
+			}
+			if pos == -1 {
+				return nil, nil, fmt.Errorf("Malformed condition '%v' in attribute where. Expected conditions like 'year=2024'", part)
+			}
+			var err error
+			if c.column, err = index(strings.TrimSpace(part[:pos])); err != nil {
+				return nil, nil, err
+			}
+			c.value = strings.TrimSpace(part[pos+len(c.op):])
+			conditions = append(conditions, c)
+		}
+		var selected [][]string
+		for _, row := range rows {
+			match := true
+			for _, c := range conditions {
+				v := cell(row, c.column)
+				cmp := compareCells(v, c.value)
+				switch c.op {
+				case "=":
+					match = cmp == 0
+				case "!=":
+					match = cmp != 0
+				case "<":
+					match = cmp < 0
+				case "<=":
+					match = cmp <= 0
+				case ">":
+					match = cmp > 0
+				case ">=":
+					match = cmp >= 0
+				case "~":
+					match = strings.Contains(v, c.value)
+				}
+				if !match {
+					break
+				}
+			}
+			if match {
+				selected = append(selected, row)
+			}
+		}
+		rows = selected
+	}
+
+	if spec, ok := attrs["sort"]; ok {
+		var keys []int
+		var descending []bool
+		for _, name := range strings.Split(spec, ",") {
+			name = strings.TrimSpace(name)
+			desc := strings.HasPrefix(name, "-")
+			i, err := index(strings.TrimPrefix(name, "-"))
+			if err != nil {
+				return nil, nil, err
+			}
+			keys = append(keys, i)
+			descending = append(descending, desc)
+		}
+		sort.SliceStable(rows, func(a, b int) bool {
+			for k, i := range keys {
+				cmp := compareCells(cell(rows[a], i), cell(rows[b], i))
+				if cmp != 0 {
+					return (cmp < 0) != descending[k]
+				}
+			}
+			return false
+		})
+	}
+
+	if spec, ok := attrs["limit"]; ok {
+		n, err := strconv.Atoi(spec)
+		if err != nil || n < 0 {
+			return nil, nil, fmt.Errorf("Malformed limit '%v'", spec)
+		}
+		if n < len(rows) {
+			rows = rows[:n]
+		}
+	}
+
+	if spec, ok := attrs["columns"]; ok {
+		var names []string
+		var selected []int
+		for _, part := range strings.Split(spec, ",") {
+			name, label := part, part
+			if pos := strings.Index(part, "="); pos != -1 {
+				name, label = part[:pos], part[pos+1:]
+			}
+			i, err := index(strings.TrimSpace(name))
+			if err != nil {
+				return nil, nil, err
+			}
+			names = append(names, strings.TrimSpace(label))
+			selected = append(selected, i)
+		}
+		var result [][]string
+		for _, row := range rows {
+			r := make([]string, len(selected))
+			for k, i := range selected {
+				r[k] = cell(row, i)
+			}
+			result = append(result, r)
+		}
+		header, rows = names, result
+	}
+	return header, rows, nil
+}
+
+// compareCells compares two cells numerically if both are numbers and as strings otherwise.
+func compareCells(a, b string) int {
+	fa, errA := strconv.ParseFloat(strings.TrimSpace(a), 64)
+	fb, errB := strconv.ParseFloat(strings.TrimSpace(b), 64)
+	if errA == nil && errB == nil {
+		switch {
+		case fa < fb:
+			return -1
+		case fa > fb:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(a, b)
+}
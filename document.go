@@ -1,9 +1,5 @@
 package main
 
-import (
-	"fmt"
-)
-
 /***
  * Classes and functions in node file represent a parsed document.
  * This parsed document is accessible to HTML templates.
@@ -11,8 +7,6 @@ import (
  * Some utility functions in node file are available to HTML templates as well.
  */
 
-var uniqueID int
-
 // Node is the basic type for all structs that build a document tree.
 type Node interface {
 	NodeName() string
@@ -51,9 +45,21 @@ type DocumentNode struct {
 	// Parsed attributes
 	Attributes map[string]string
 	Colspan    int
+	Rowspan    int
 	Config     map[string]interface{}
 	Indent     int
-	ctx        *NodeContext
+	// Line of the markdown file in which the node starts (counting from 1). The root node has line 0.
+	Line int
+	ctx  *NodeContext
+	// Ids in use by the document. Only set for the root node, see `ids`.
+	idRegistry *idRegistry
+	// Annotations of a #code node or nil
+	code *codeAnnotations
+	// Layout attributes of a table node or nil
+	table *tableLayout
+	// Alignment of a table cell and whether it is a row header
+	align     string
+	rowHeader bool
 }
 
 // NodeName returns a type string that can be used to filter nodes by their type.
@@ -191,8 +197,7 @@ func (node *StyleNode) Class() string {
 func (node *StyleNode) ForceID() string {
 	if node.ID() == "" {
 		if node.forcedID == "" {
-			node.forcedID = fmt.Sprintf("_id_%v", uniqueID)
-			uniqueID++
+			node.forcedID = node.documentNode().ids().next()
 		}
 		return node.forcedID
 	}
@@ -238,6 +243,10 @@ func (node *StyleNode) Source() string {
 type MathNode struct {
 	Text   string
 	Parent NodeWithText
+	// Line of the markdown file in which the formula starts (counting from 1)
+	Line int
+	// True if a warning about the formula has been logged already
+	warned bool
 }
 
 // NodeName returns a type string that can be used to filter nodes by their type.
@@ -286,7 +295,9 @@ type EntityNode struct {
 	// This includes all counter increments and resets triggered by node node
 	Counters map[string]int
 	// The text following the double point.
-	Value    string
+	Value string
+	// Line of the markdown file in which the entity is used (counting from 1).
+	Line     int
 	forcedID string
 	ctx      *EntityContext
 }
@@ -345,8 +356,7 @@ func (node *EntityNode) Class() string {
 func (node *EntityNode) ForceID() string {
 	if node.ID() == "" {
 		if node.forcedID == "" {
-			node.forcedID = fmt.Sprintf("_id_%v", uniqueID)
-			uniqueID++
+			node.forcedID = node.Parent.documentNode().ids().next()
 		}
 		return node.forcedID
 	}
@@ -387,6 +397,10 @@ func (node *DocumentNode) ID() string {
 	if label, ok := node.Attributes["label"]; ok {
 		return label
 	}
+	// Headings are assigned their id when the id registry is created
+	if node.forcedID == "" && node.isAnchoredHeading() {
+		node.ids()
+	}
 	if node.forcedID != "" {
 		return node.forcedID
 	}
@@ -496,12 +510,19 @@ func (node *DocumentNode) Source() string {
 */
 
 // PlainText returns the text embedded in TextNode objects which are direct leafes to this node.
+// Text inside of styles, e.g. bold text, is included as well.
 func (node *DocumentNode) PlainText() string {
+	return plainText(node.Text)
+}
+
+func plainText(nodes []Node) string {
 	var result string
-	for _, t := range node.Text {
+	for _, t := range nodes {
 		switch t.(type) {
 		case *TextNode:
 			result += t.(*TextNode).Text
+		case *StyleNode:
+			result += plainText(t.(*StyleNode).Text)
 		}
 	}
 	return result
@@ -512,8 +533,7 @@ func (node *DocumentNode) PlainText() string {
 func (node *DocumentNode) ForceID() string {
 	if node.ID() == "" {
 		if node.forcedID == "" {
-			node.forcedID = fmt.Sprintf("_id_%v", uniqueID)
-			uniqueID++
+			node.forcedID = node.documentNode().ids().next()
 		}
 		return node.forcedID
 	}
@@ -553,7 +573,7 @@ func (node *DocumentNode) ColumnCount() (int, error) {
 	return 0, nil
 }
 
-// Columns returns the columns, if node node represents a table.
+// Columns returns the cells of the last header row, if node node represents a table.
 func (node *DocumentNode) Columns() ([]*DocumentNode, error) {
 	//	if node.Tag != "table" {
 	//		return nil, errors.New("Not a table")
@@ -563,6 +583,8 @@ func (node *DocumentNode) Columns() ([]*DocumentNode, error) {
 		if c.Tag == "#thead" {
 			for _, row := range c.Children {
 				if row.Tag == "#thead-row" {
+					// Tables with several header rows are labelled by the last one
+					result = result[:0]
 					for _, cell := range row.Children {
 						if cell.Tag == "#thead-cell" {
 							result = append(result, cell)
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"strings"
+)
+
+// footnote is a footnote referenced by `~fn:label~` or `~fn:inline text~`.
+type footnote struct {
+	Number int
+	// The first entity referencing the footnote
+	ref *EntityNode
+	// The #fn node defining the footnote or nil for inline footnotes
+	def *DocumentNode
+}
+
+// footnoteDefinition returns the #fn node with the label `label` or nil.
+func footnoteDefinition(doc *DocumentNode, label string) *DocumentNode {
+	for _, n := range doc.DocumentNodes("#fn") {
+		if n.Attributes["label"] == label {
+			return n
+		}
+	}
+	return nil
+}
+
+// textEntities returns the entities in the text of `node`, excluding the text of child nodes.
+func textEntities(node *DocumentNode) []*EntityNode {
+	var result []*EntityNode
+	for _, t := range node.Text {
+		if e, ok := t.(*EntityNode); ok {
+			result = append(result, e)
+		} else if s, ok := t.(*StyleNode); ok {
+			result = append(result, s.entities()...)
+		}
+	}
+	return result
+}
+
+// collectFootnotes groups the footnotes of a document by the #footnotes node listing them.
+// Footnotes referenced after the last #footnotes node are listed at the end of the document under the key nil.
+func collectFootnotes(doc *DocumentNode) map[*DocumentNode][]*footnote {
+	groups := make(map[*DocumentNode][]*footnote)
+	seen := make(map[int]bool)
+	var current []*footnote
+	var walk func(node *DocumentNode)
+	walk = func(node *DocumentNode) {
+		if node.Tag == "#footnotes" {
+			groups[node] = current
+			current = nil
+			return
+		}
+		for _, e := range textEntities(node) {
+			if e.Name != "fn" || seen[e.Counters["fn"]] {
+				continue
+			}
+			seen[e.Counters["fn"]] = true
+			current = append(current, &footnote{Number: e.Counters["fn"], ref: e, def: footnoteDefinition(doc, e.Value)})
+		}
+		for _, c := range node.Children {
+			walk(c)
+		}
+	}
+	walk(doc)
+	groups[nil] = current
+	return groups
+}
+
+// footnoteIDs returns the id of the first reference to footnote `n` and the id of the footnote itself.
+// The ids are "fnref-n" and "fn-n", unless a label or heading of the document uses them already.
+func (reg *idRegistry) footnoteIDs(n int) (string, string) {
+	if reg.footnotes == nil {
+		reg.footnotes = make(map[int][2]string)
+	}
+	ids, ok := reg.footnotes[n]
+	if !ok {
+		ids = [2]string{reg.unique(fmt.Sprintf("fnref-%v", n)), reg.unique(fmt.Sprintf("fn-%v", n))}
+		reg.footnotes[n] = ids
+	}
+	return ids[0], ids[1]
+}
+
+// footnoteRef returns the HTML of a footnote reference.
+// Only the first reference to a footnote has an id, because it is the target of the back-link.
+func (gen *HTMLGenerator) footnoteRef(entity *EntityNode) string {
+	n := entity.Counters["fn"]
+	doc := entity.Parent.documentNode()
+	if doc.Document != nil {
+		doc = doc.Document
+	}
+	refID, fnID := doc.ids().footnoteIDs(n)
+	id := ""
+	for _, e := range doc.Entities() {
+		if e.Name == "fn" && e.Counters["fn"] == n {
+			if e == entity {
+				id = fmt.Sprintf(` id="%v"`, html.EscapeString(refID))
+			}
+			break
+		}
+	}
+	return fmt.Sprintf(`<sup class="footnote-ref"><a href="#%v"%v>%v</a></sup>`, html.EscapeString(fnID), id, n)
+}
+
+// footnotes returns the HTML of the footnotes listed by the #footnotes node `node`.
+// If `node` is the root node, the result lists the footnotes referenced after the last #footnotes node.
+func (gen *HTMLGenerator) footnotes(node *DocumentNode) (string, error) {
+	doc := node
+	key := node
+	if node.Parent == nil {
+		key = nil
+	} else {
+		doc = node.Document
+	}
+	list := collectFootnotes(doc)[key]
+	if len(list) == 0 {
+		return "", nil
+	}
+	result := `<section class="footnotes"><ol>`
+	for _, fn := range list {
+		var text string
+		if fn.def != nil {
+			str, err := gen.innerHTML(fn.def)
+			if err != nil {
+				return "", err
+			}
+			text = str
+		} else {
+			text = html.EscapeString(unescapeEntityValue(fn.ref.Value))
+		}
+		refID, fnID := doc.ids().footnoteIDs(fn.Number)
+		result += fmt.Sprintf(`<li id="%v" value="%v">%v <a href="#%v" class="footnote-backref">&#8617;</a></li>`, html.EscapeString(fnID), fn.Number, text, html.EscapeString(refID))
+	}
+	return result + `</ol></section>`, nil
+}
+
+// unescapeEntityValue removes the backslashes which escape characters such as spaces in the value of an entity.
+func unescapeEntityValue(value string) string {
+	var b strings.Builder
+	escaped := false
+	for _, r := range value {
+		if r == '\\' && !escaped {
+			escaped = true
+			continue
+		}
+		escaped = false
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,604 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html"
+	"math"
+	"math/rand"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/weistn/template"
+)
+
+// libraryFuncs are the general purpose functions available to all templates.
+//
+// Like `prefix` and `sortBy`, functions that process a string or list take it as their last argument,
+// such that they can be used in pipelines, e.g. `{{.Title | truncate 20}}`.
+// The functions of this library report errors in the form "name: message".
+var libraryFuncs = template.FuncMap{
+	// Strings
+	"lower":     strings.ToLower,
+	"upper":     strings.ToUpper,
+	"trim":      strings.TrimSpace,
+	"contains":  funcContains,
+	"hasSuffix": funcHasSuffix,
+	"replace":   funcReplace,
+	"split":     funcSplit,
+	"join":      funcJoin,
+	"title":     funcTitle,
+	"truncate":  funcTruncate,
+	"slugify":   slugify,
+	"findRE":    funcFindRE,
+	"matchRE":   funcMatchRE,
+	"replaceRE": funcReplaceRE,
+	// Math
+	"add":   funcAdd,
+	"sub":   funcSub,
+	"mul":   funcMul,
+	"div":   funcDiv,
+	"mod":   funcMod,
+	"min":   funcMin,
+	"max":   funcMax,
+	"round": funcRound,
+	"seq":   funcSeq,
+	// Collections
+	"first":     funcFirst,
+	"after":     funcAfter,
+	"where":     funcWhere,
+	"in":        funcIn,
+	"union":     funcUnion,
+	"intersect": funcIntersect,
+	"groupBy":   funcGroupBy,
+	"dict":      funcDict,
+	"slice":     funcSlice,
+	"shuffle":   funcShuffle,
+	// Encodings
+	"jsonify":      funcJsonify,
+	"urlize":       funcUrlize,
+	"base64Encode": funcBase64Encode,
+	"base64Decode": funcBase64Decode,
+	"htmlEscape":   html.EscapeString,
+	"htmlUnescape": html.UnescapeString,
+}
+
+// Group is the result of `groupBy`.
+type Group struct {
+	Key   string
+	Items []interface{}
+}
+
+func funcError(name string, format string, args ...interface{}) error {
+	return fmt.Errorf(name+": "+format, args...)
+}
+
+func funcContains(substr string, str string) bool {
+	return strings.Contains(str, substr)
+}
+
+func funcHasSuffix(suffix string, str string) bool {
+	return strings.HasSuffix(str, suffix)
+}
+
+func funcReplace(old string, new string, str string) string {
+	return strings.Replace(str, old, new, -1)
+}
+
+func funcSplit(sep string, str string) []string {
+	return strings.Split(str, sep)
+}
+
+func funcJoin(sep string, list reflect.Value) (string, error) {
+	a, err := strslice(list)
+	if err != nil {
+		return "", funcError("join", "%v", err)
+	}
+	return strings.Join(a, sep), nil
+}
+
+// funcTitle turns the first letter of each word into upper case.
+func funcTitle(str string) string {
+	prev := ' '
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(prev) || prev == '-' {
+			prev = r
+			return unicode.ToTitle(r)
+		}
+		prev = r
+		return r
+	}, str)
+}
+
+// funcTruncate shortens `str` to at most `length` characters (not bytes) followed by an ellipsis.
+func funcTruncate(length int, str string) (string, error) {
+	if length < 0 {
+		return "", funcError("truncate", "negative length %v", length)
+	}
+	runes := []rune(str)
+	if len(runes) <= length {
+		return str, nil
+	}
+	return strings.TrimRightFunc(string(runes[:length]), unicode.IsSpace) + "…", nil
+}
+
+// slugify turns `str` into a lower case string that consists of letters, digits and dashes only.
+func slugify(str string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(str) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && b.Len() > 0 {
+				b.WriteRune('-')
+			}
+			dash = false
+			b.WriteRune(r)
+		} else {
+			dash = true
+		}
+	}
+	return b.String()
+}
+
+func funcFindRE(pattern string, str string) ([]string, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, funcError("findRE", "%v", err)
+	}
+	return re.FindAllString(str, -1), nil
+}
+
+func funcMatchRE(pattern string, str string) (bool, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return false, funcError("matchRE", "%v", err)
+	}
+	return re.MatchString(str), nil
+}
+
+func funcReplaceRE(pattern string, repl string, str string) (string, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return "", funcError("replaceRE", "%v", err)
+	}
+	return re.ReplaceAllString(str, repl), nil
+}
+
+// toNumber converts integers, floats and strings containing a number, as found in YAML files, to a float64.
+func toNumber(name string, v interface{}) (float64, error) {
+	value := reflect.ValueOf(v)
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(value.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(value.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return value.Float(), nil
+	case reflect.String:
+		if f, err := strconv.ParseFloat(strings.TrimSpace(value.String()), 64); err == nil {
+			return f, nil
+		}
+	}
+	return 0, funcError(name, "%v is not a number", v)
+}
+
+// toInt returns the value of an integer or of a string containing an integer without a detour via float64.
+func toInt(v interface{}) (int64, bool) {
+	value := reflect.ValueOf(v)
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return value.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if value.Uint() <= math.MaxInt64 {
+			return int64(value.Uint()), true
+		}
+	case reflect.String:
+		if i, err := strconv.ParseInt(strings.TrimSpace(value.String()), 10, 64); err == nil {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// arithmetic applies `iop` to `a` and `b` if both operands are integers and yields an int.
+// Otherwise it applies `fop` and yields a float64.
+func arithmetic(name string, a interface{}, b interface{}, iop func(x, y int64) int64, fop func(x, y float64) float64) (interface{}, error) {
+	if x, ok := toInt(a); ok {
+		if y, ok := toInt(b); ok {
+			return int(iop(x, y)), nil
+		}
+	}
+	x, err := toNumber(name, a)
+	if err != nil {
+		return nil, err
+	}
+	y, err := toNumber(name, b)
+	if err != nil {
+		return nil, err
+	}
+	return fop(x, y), nil
+}
+
+func funcAdd(a interface{}, b interface{}) (interface{}, error) {
+	return arithmetic("add", a, b, func(x, y int64) int64 { return x + y }, func(x, y float64) float64 { return x + y })
+}
+
+func funcSub(a interface{}, b interface{}) (interface{}, error) {
+	return arithmetic("sub", a, b, func(x, y int64) int64 { return x - y }, func(x, y float64) float64 { return x - y })
+}
+
+func funcMul(a interface{}, b interface{}) (interface{}, error) {
+	return arithmetic("mul", a, b, func(x, y int64) int64 { return x * y }, func(x, y float64) float64 { return x * y })
+}
+
+// funcDiv divides `a` by `b`. The division of two integers yields an integer.
+func funcDiv(a interface{}, b interface{}) (interface{}, error) {
+	if y, err := toNumber("div", b); err == nil && y == 0 {
+		return nil, funcError("div", "division by zero")
+	}
+	return arithmetic("div", a, b, func(x, y int64) int64 { return x / y }, func(x, y float64) float64 { return x / y })
+}
+
+func funcMod(a interface{}, b interface{}) (int, error) {
+	x, xint := toInt(a)
+	y, yint := toInt(b)
+	if !xint || !yint {
+		if _, err := toNumber("mod", a); err != nil {
+			return 0, err
+		}
+		if _, err := toNumber("mod", b); err != nil {
+			return 0, err
+		}
+		return 0, funcError("mod", "operands must be integers")
+	}
+	if y == 0 {
+		return 0, funcError("mod", "division by zero")
+	}
+	return int(x % y), nil
+}
+
+func funcMin(a interface{}, b interface{}) (interface{}, error) {
+	return arithmetic("min", a, b, func(x, y int64) int64 {
+		if x < y {
+			return x
+		}
+		return y
+	}, math.Min)
+}
+
+func funcMax(a interface{}, b interface{}) (interface{}, error) {
+	return arithmetic("max", a, b, func(x, y int64) int64 {
+		if x > y {
+			return x
+		}
+		return y
+	}, math.Max)
+}
+
+// funcRound rounds `a` to the given number of decimal places.
+func funcRound(places int, a interface{}) (float64, error) {
+	x, err := toNumber("round", a)
+	if err != nil {
+		return 0, err
+	}
+	p := math.Pow(10, float64(places))
+	return math.Round(x*p) / p, nil
+}
+
+// funcSeq returns the integers 1 to n.
+func funcSeq(n int) ([]int, error) {
+	if n < 0 {
+		return nil, funcError("seq", "negative length %v", n)
+	}
+	list := make([]int, n)
+	for i := range list {
+		list[i] = i + 1
+	}
+	return list, nil
+}
+
+// listValues returns the elements of an array or slice.
+func listValues(name string, list reflect.Value) ([]interface{}, error) {
+	switch list.Kind() {
+	case reflect.Array, reflect.Slice:
+		l := list.Len()
+		result := make([]interface{}, 0, l)
+		for i := 0; i < l; i++ {
+			result = append(result, list.Index(i).Interface())
+		}
+		return result, nil
+	case reflect.Invalid:
+		return nil, nil
+	}
+	return nil, funcError(name, "expected a list instead of type %s", list.Type())
+}
+
+// fieldValue returns the value of a map entry, struct field or method without arguments named `field`.
+func fieldValue(name string, item interface{}, field string) (interface{}, error) {
+	v := reflect.ValueOf(item)
+	if !v.IsValid() {
+		return nil, nil
+	}
+	if v.Kind() == reflect.Map && v.Type().Key().Kind() == reflect.String {
+		result := v.MapIndex(reflect.ValueOf(field).Convert(v.Type().Key()))
+		if !result.IsValid() {
+			return nil, nil
+		}
+		return result.Interface(), nil
+	}
+	if method := v.MethodByName(field); method.IsValid() {
+		if method.Type().NumIn() != 0 {
+			return nil, funcError(name, "method %v expects additional arguments", field)
+		}
+		results := method.Call(nil)
+		if len(results) == 2 && !results[1].IsNil() {
+			return nil, funcError(name, "%v", results[1].Interface())
+		}
+		return results[0].Interface(), nil
+	}
+	s := v
+	if s.Kind() == reflect.Ptr {
+		s = s.Elem()
+	}
+	if s.Kind() == reflect.Struct {
+		if result := s.FieldByName(field); result.IsValid() && result.CanInterface() {
+			return result.Interface(), nil
+		}
+	}
+	return nil, funcError(name, "%v is neither a field nor a method of type %T", field, item)
+}
+
+func funcFirst(n int, list reflect.Value) ([]interface{}, error) {
+	items, err := listValues("first", list)
+	if err != nil {
+		return nil, err
+	}
+	if n < 0 {
+		return nil, funcError("first", "negative length %v", n)
+	}
+	if n > len(items) {
+		n = len(items)
+	}
+	return items[:n], nil
+}
+
+func funcAfter(n int, list reflect.Value) ([]interface{}, error) {
+	items, err := listValues("after", list)
+	if err != nil {
+		return nil, err
+	}
+	if n < 0 {
+		return nil, funcError("after", "negative offset %v", n)
+	}
+	if n > len(items) {
+		n = len(items)
+	}
+	return items[n:], nil
+}
+
+// funcWhere returns all items of a list whose `field` matches a value.
+// It is called either as `where field value list` or as `where field operator value list`,
+// where the operator is one of "==", "!=", "<", "<=", ">", ">=", "in" and "not in".
+// Items lacking the field never match.
+func funcWhere(field string, args ...reflect.Value) ([]interface{}, error) {
+	op := "=="
+	if len(args) == 3 {
+		if !args[0].IsValid() {
+			return nil, funcError("where", "missing operator")
+		}
+		op = strval(args[0])
+		args = args[1:]
+	} else if len(args) != 2 {
+		return nil, funcError("where", "wrong number of arguments")
+	}
+	var match interface{}
+	if args[0].IsValid() {
+		match = args[0].Interface()
+	}
+	items, err := listValues("where", args[1])
+	if err != nil {
+		return nil, err
+	}
+	result := []interface{}{}
+	for _, item := range items {
+		v, err := fieldValue("where", item, field)
+		if err != nil {
+			return nil, err
+		}
+		if v == nil || match == nil {
+			continue
+		}
+		ok, err := compare(op, v, match)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			result = append(result, item)
+		}
+	}
+	return result, nil
+}
+
+// compare applies the operator of `where`. Numbers are compared numerically, all other values as strings.
+func compare(op string, a interface{}, b interface{}) (bool, error) {
+	switch op {
+	case "in", "not in":
+		in, err := funcIn(reflect.ValueOf(b), reflect.ValueOf(a))
+		return in == (op == "in"), err
+	}
+	var c int
+	x, errx := toNumber("where", a)
+	y, erry := toNumber("where", b)
+	if errx == nil && erry == nil {
+		if x < y {
+			c = -1
+		} else if x > y {
+			c = 1
+		}
+	} else {
+		c = strings.Compare(strval(reflect.ValueOf(a)), strval(reflect.ValueOf(b)))
+	}
+	switch op {
+	case "==", "=", "eq":
+		return c == 0, nil
+	case "!=", "ne":
+		return c != 0, nil
+	case "<", "lt":
+		return c < 0, nil
+	case "<=", "le":
+		return c <= 0, nil
+	case ">", "gt":
+		return c > 0, nil
+	case ">=", "ge":
+		return c >= 0, nil
+	}
+	return false, funcError("where", "unknown operator %v", op)
+}
+
+// funcIn reports whether `item` is an element of the list `set` or a substring of the string `set`.
+func funcIn(set reflect.Value, item reflect.Value) (bool, error) {
+	if !item.IsValid() {
+		return false, nil
+	}
+	if set.Kind() == reflect.String {
+		return strings.Contains(set.String(), strval(item)), nil
+	}
+	items, err := listValues("in", set)
+	if err != nil {
+		return false, err
+	}
+	for _, x := range items {
+		if reflect.DeepEqual(x, item.Interface()) || (reflect.TypeOf(x) != item.Type() && strval(reflect.ValueOf(x)) == strval(item)) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// funcUnion returns the elements of both lists without duplicates.
+func funcUnion(a reflect.Value, b reflect.Value) ([]interface{}, error) {
+	x, err := listValues("union", a)
+	if err != nil {
+		return nil, err
+	}
+	y, err := listValues("union", b)
+	if err != nil {
+		return nil, err
+	}
+	result := []interface{}{}
+	for _, item := range append(x, y...) {
+		if !inList(result, item) {
+			result = append(result, item)
+		}
+	}
+	return result, nil
+}
+
+// funcIntersect returns the elements which are contained in both lists without duplicates.
+func funcIntersect(a reflect.Value, b reflect.Value) ([]interface{}, error) {
+	x, err := listValues("intersect", a)
+	if err != nil {
+		return nil, err
+	}
+	y, err := listValues("intersect", b)
+	if err != nil {
+		return nil, err
+	}
+	result := []interface{}{}
+	for _, item := range x {
+		if inList(y, item) && !inList(result, item) {
+			result = append(result, item)
+		}
+	}
+	return result, nil
+}
+
+// funcGroupBy groups the items of a list by the string value of `field`.
+// The groups are ordered by the first occurrence of their key. Items lacking the field are grouped under the empty key.
+func funcGroupBy(field string, list reflect.Value) ([]*Group, error) {
+	items, err := listValues("groupBy", list)
+	if err != nil {
+		return nil, err
+	}
+	groups := []*Group{}
+	index := make(map[string]*Group)
+	for _, item := range items {
+		v, err := fieldValue("groupBy", item, field)
+		if err != nil {
+			return nil, err
+		}
+		key := ""
+		if v != nil {
+			key = strval(reflect.ValueOf(v))
+		}
+		g, ok := index[key]
+		if !ok {
+			g = &Group{Key: key}
+			index[key] = g
+			groups = append(groups, g)
+		}
+		g.Items = append(g.Items, item)
+	}
+	return groups, nil
+}
+
+func funcDict(pairs ...interface{}) (map[string]interface{}, error) {
+	if len(pairs)%2 != 0 {
+		return nil, funcError("dict", "expected an even number of arguments")
+	}
+	m := make(map[string]interface{}, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		key, ok := pairs[i].(string)
+		if !ok {
+			return nil, funcError("dict", "key %v is not a string", pairs[i])
+		}
+		m[key] = pairs[i+1]
+	}
+	return m, nil
+}
+
+func funcSlice(items ...interface{}) []interface{} {
+	return items
+}
+
+func funcShuffle(list reflect.Value) ([]interface{}, error) {
+	items, err := listValues("shuffle", list)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]interface{}, len(items))
+	for i, j := range rand.Perm(len(items)) {
+		result[i] = items[j]
+	}
+	return result, nil
+}
+
+func funcJsonify(v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", funcError("jsonify", "%v", err)
+	}
+	return string(data), nil
+}
+
+// funcUrlize turns `str` into a string that can be used as a URL path, e.g. "Hello World" becomes "hello-world".
+func funcUrlize(str string) string {
+	return (&url.URL{Path: strings.Join(strings.Fields(strings.ToLower(str)), "-")}).EscapedPath()
+}
+
+func funcBase64Encode(str string) string {
+	return base64.StdEncoding.EncodeToString([]byte(str))
+}
+
+func funcBase64Decode(str string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(str)
+	if err != nil {
+		return "", funcError("base64Decode", "%v", err)
+	}
+	return string(data), nil
+}
@@ -68,6 +68,8 @@ type TagDefinition struct {
 	// FirstChild of true forces a parent listed in PossibleParents to host this tag as its first child tag.
 	// If this is not possible, the parent tag is closed and reopened.
 	FirstChild bool
+	// Level of a heading (1 to 4) which is listed in the table of contents, or 0 if the tag is no heading.
+	Heading int
 	// Determines whether the tag can be used (directly or via its default parents) as a child of a tag with ParentHood.
 	// This value is derived from the default parents. Unless these insist on being tied to #root,
 	// a tag can be used in block scope.
@@ -278,32 +280,52 @@ func (grammar *Grammar) loadBuiltinTags() {
 		return m
 	}
 
-	grammar.addBuiltinTag(&TagDefinition{grammar, "#root", `{{.Content}}`, nil, sectionNormal, []string{}, "", nil, "", nil, nil, nil, RootParentHood, false, false})
-	grammar.addBuiltinTag(&TagDefinition{grammar, "#p", `<p{{if .ID}} id="{{.ID}}"{{end}}{{if .Class}} class="{{.Class}}"{{end}}{{if .Style}} style="{{.Style}}"{{end}}>{{.Content}}</p>`, nil, sectionParagraph, []string{}, "", nil, "", nil, nil, nil, TextParentHood, false, true})
-	grammar.addBuiltinTag(&TagDefinition{grammar, "#ul", `<ul{{if .ID}} id="{{.ID}}"{{end}}{{if .Class}} class="{{.Class}}"{{end}}{{if .Style}} style="{{.Style}}"{{end}}>{{.Content}}</ul>`, nil, sectionNormal, []string{ /*"#ul", "#ol"*/ }, "", nil, "", nil, nil, nil, IndentParentHood, false, true})
-	grammar.addBuiltinTag(&TagDefinition{grammar, "#li", `<li{{if .ID}} id="{{.ID}}"{{end}}{{if .Class}} class="{{.Class}}"{{end}}{{if .Style}} style="{{.Style}}"{{end}}>{{.Content}}</li>`, newString("#ul"), sectionNormal, []string{"#ul", "#ol"}, "", nil, "", nil, nil, nil, TextParentHood, false, false})
-	grammar.addBuiltinTag(&TagDefinition{grammar, "#ol", `<ol{{if .ID}} id="{{.ID}}"{{end}}{{if .Class}} class="{{.Class}}"{{end}}{{if .Style}} style="{{.Style}}"{{end}}>{{.Content}}</ol>`, nil, sectionNormal, []string{ /*"#ul", "#ol"*/ }, "", nil, "", nil, nil, nil, IndentParentHood, false, true})
-	grammar.addBuiltinTag(&TagDefinition{grammar, "#", `<h1{{if .ID}} id="{{.ID}}"{{end}}{{if .Class}} class="{{.Class}}"{{end}}{{if .Style}} style="{{.Style}}"{{end}}>{{.Content}}</h1>`, nil, sectionNormal, []string{}, "", nil, "", nil, nil, nil, TextParentHood, false, true})
-	grammar.addBuiltinTag(&TagDefinition{grammar, "##", `<h2{{if .ID}} id="{{.ID}}"{{end}}{{if .Class}} class="{{.Class}}"{{end}}{{if .Style}} style="{{.Style}}"{{end}}>{{.Content}}</h2>`, nil, sectionNormal, []string{}, "", nil, "", nil, nil, nil, TextParentHood, false, true})
-	grammar.addBuiltinTag(&TagDefinition{grammar, "###", `<h3{{if .ID}} id="{{.ID}}"{{end}}{{if .Class}} class="{{.Class}}"{{end}}{{if .Style}} style="{{.Style}}"{{end}}>{{.Content}}</h3>`, nil, sectionNormal, []string{}, "", nil, "", nil, nil, nil, TextParentHood, false, true})
-	grammar.addBuiltinTag(&TagDefinition{grammar, "####", `<h4{{if .ID}} id="{{.ID}}"{{end}}{{if .Class}} class="{{.Class}}"{{end}}{{if .Style}} style="{{.Style}}"{{end}}>{{.Content}}</h4>`, nil, sectionNormal, []string{}, "", nil, "", nil, nil, nil, TextParentHood, false, true})
-	grammar.addBuiltinTag(&TagDefinition{grammar, "#table", `<table{{if .ID}} id="{{.ID}}"{{end}}{{if .Class}} class="{{.Class}}"{{end}}{{if .Style}} style="{{.Style}}"{{end}}>{{.Content}}</table>`, nil, sectionTable, []string{}, "Table", nil, "", nil, nil, createConfig("Table"), NoParentHood, false, true})
-	grammar.addBuiltinTag(&TagDefinition{grammar, "#tbody", `<tbody{{if .ID}} id="{{.ID}}"{{end}}{{if .Class}} class="{{.Class}}"{{end}}{{if .Style}} style="{{.Style}}"{{end}}>{{.Content}}</tbody>`, newString("#table"), sectionTable, []string{"#table", "#pie", "#chart"}, "", nil, "", nil, nil, nil, NoParentHood, false, false})
-	grammar.addBuiltinTag(&TagDefinition{grammar, "#thead", `<thead{{if .ID}} id="{{.ID}}"{{end}}{{if .Class}} class="{{.Class}}"{{end}}{{if .Style}} style="{{.Style}}"{{end}}>{{.Content}}</thead>`, newString("#table"), sectionTable, []string{"#table", "#pie", "#chart"}, "", nil, "", nil, nil, nil, NoParentHood, false, false})
-	grammar.addBuiltinTag(&TagDefinition{grammar, "#tbody-row", `<tr{{if .ID}} id="{{.ID}}"{{end}}{{if .Class}} class="{{.Class}}"{{end}}{{if .Style}} style="{{.Style}}"{{end}}>{{.Content}}</tr>`, newString("#tbody"), sectionTable, []string{"#tbody"}, "", nil, "", nil, nil, nil, NoParentHood, false, false})
-	grammar.addBuiltinTag(&TagDefinition{grammar, "#thead-row", `<tr{{if .ID}} id="{{.ID}}"{{end}}{{if .Class}} class="{{.Class}}"{{end}}{{if .Style}} style="{{.Style}}"{{end}}>{{.Content}}</tr>`, newString("#thead"), sectionTable, []string{"#thead"}, "", nil, "", nil, nil, nil, NoParentHood, false, false})
-	grammar.addBuiltinTag(&TagDefinition{grammar, "#tbody-cell", `<td{{if .ID}} id="{{.ID}}"{{end}}{{if .Class}} class="{{.Class}}"{{end}}{{if .Style}} style="{{.Style}}"{{end}}{{if ne .Colspan 1}} colspan="{{.Colspan}}"{{end}}>{{.Content}}</td>`, newString("#tbody-row"), sectionTable, []string{"#tbody-row"}, "", nil, "", nil, nil, nil, TextParentHood, false, false})
-	grammar.addBuiltinTag(&TagDefinition{grammar, "#thead-cell", `<th{{if .ID}} id="{{.ID}}"{{end}}{{if .Class}} class="{{.Class}}"{{end}}{{if .Style}} style="{{.Style}}"{{end}}{{if ne .Colspan 1}} colspan="{{.Colspan}}"{{end}}>{{.Content}}</th>`, newString("#thead-row"), sectionTable, []string{"#thead-row"}, "", nil, "", nil, nil, nil, TextParentHood, false, false})
-	grammar.addBuiltinTag(&TagDefinition{grammar, "#code", `<pre{{if .ID}} id="{{.ID}}"{{end}}{{if .HasClass "nosyntax"}} class="{{.Class}}"{{else}} class="prettyprint {{.Class}}"{{end}}{{if .Style}} style="{{.Style}}"{{end}}>{{.Content}}</pre>`, nil, sectionCode, []string{}, "Sample", nil, "class", nil, nil, createConfig("Sample"), TextParentHood, false, true})
-	grammar.addBuiltinTag(&TagDefinition{grammar, "#math", `<div{{if .ID}} id="{{.ID}}"{{end}}{{if .Class}} class="{{.Class}}"{{end}}{{if .Style}} style="{{.Style}}"{{end}}><span class="math">\[{{.Content}}\]</span></div>`, nil, sectionMath, []string{}, "Equation", nil, "", nil, nil, createConfig("Equation"), TextParentHood, false, true})
-	grammar.addBuiltinTag(&TagDefinition{grammar, "#bib", `<p{{if .ID}} id="{{.ID}}"{{end}} class="bibentry {{.Class}}"{{if .Style}} style="{{.Style}}"{{end}}>[{{.Counters.bib}}] {{.Content}}</p>`, nil, sectionNormal, []string{}, "bib", nil, "label", nil, nil, nil, TextParentHood, false, true})
-	grammar.addBuiltinTag(&TagDefinition{grammar, "#pie", `<div{{if .ID}} id="{{.ID}}"{{end}}{{if .Class}} class="{{.Class}}"{{end}}{{if .Style}} style="{{.Style}}"{{end}}>{{.Content}}</div>`, nil, sectionTable, []string{}, "Chart", nil, "", nil, nil, createConfig("Chart"), NoParentHood, false, true})
-	grammar.addBuiltinTag(&TagDefinition{grammar, "#chart", `<div{{if .ID}} id="{{.ID}}"{{end}}{{if .Class}} class="{{.Class}}"{{end}}{{if .Style}} style="{{.Style}}"{{end}}>{{.Content}}</div>`, nil, sectionTable, []string{}, "Chart", nil, "", nil, nil, createConfig("Chart"), NoParentHood, false, true})
-	grammar.addBuiltinTag(&TagDefinition{grammar, "#caption", `<div{{if .ID}} id="{{.ID}}"{{end}} class="caption {{.Class}}"{{if .Style}} style="{{.Style}}"{{end}}>{{if .PrevSibling}}{{if and .PrevSibling.TagParams.caption .PrevSibling.TagParams.counter}}<span class="{{.PrevSibling.TagName}}-counter counter">{{.PrevSibling.TagParams.caption}} {{index .Counters .PrevSibling.TagParams.counter}}:</span> {{end}}{{end}}{{.Content}}</div>`, nil, sectionNormal, []string{}, "", nil, "", nil, nil, nil, TextParentHood, false, true})
-	grammar.addBuiltinTag(&TagDefinition{grammar, ">", `<blockquote{{if .ID}} id="{{.ID}}"{{end}}{{if .Class}} class="{{.Class}}"{{end}}{{if .Style}} style="{{.Style}}"{{end}}>{{.Content}}</blockquote>`, nil, sectionNormal, []string{}, "", nil, "", nil, nil, nil, IndentParentHood, false, true})
-	grammar.addBuiltinTag(&TagDefinition{grammar, "#dl", `<dl{{if .ID}} id="{{.ID}}"{{end}}{{if .Class}} class="{{.Class}}"{{end}}{{if .Style}} style="{{.Style}}"{{end}}>{{.Content}}</dl>`, nil, sectionNormal, []string{}, "", nil, "", nil, nil, nil, IndentParentHood, false, true})
-	grammar.addBuiltinTag(&TagDefinition{grammar, "#dt", `<dt{{if .ID}} id="{{.ID}}"{{end}}{{if .Class}} class="{{.Class}}"{{end}}{{if .Style}} style="{{.Style}}"{{end}}>{{.Content}}</dt>`, newString("#dl"), sectionNormal, []string{"#dl"}, "", nil, "", nil, nil, nil, TextParentHood, false, false})
-	grammar.addBuiltinTag(&TagDefinition{grammar, "#dd", `<dd{{if .ID}} id="{{.ID}}"{{end}}{{if .Class}} class="{{.Class}}"{{end}}{{if .Style}} style="{{.Style}}"{{end}}>{{.Content}}</dd>`, newString("#dl"), sectionNormal, []string{"#dl"}, "", nil, "", nil, nil, nil, IndentParentHood, false, false})
+	grammar.addBuiltinTag(&TagDefinition{grammar, "#root", `{{.Content}}`, nil, sectionNormal, []string{}, "", nil, "", nil, nil, nil, RootParentHood, false, 0, false})
+	grammar.addBuiltinTag(&TagDefinition{grammar, "#p", `<p{{if .ID}} id="{{.ID}}"{{end}}{{if .Class}} class="{{.Class}}"{{end}}{{if .Style}} style="{{.Style}}"{{end}}>{{.Content}}</p>`, nil, sectionParagraph, []string{}, "", nil, "", nil, nil, nil, TextParentHood, false, 0, true})
+	grammar.addBuiltinTag(&TagDefinition{grammar, "#ul", `<ul{{if .ID}} id="{{.ID}}"{{end}}{{if .Class}} class="{{.Class}}"{{end}}{{if .Style}} style="{{.Style}}"{{end}}>{{.Content}}</ul>`, nil, sectionNormal, []string{ /*"#ul", "#ol"*/ }, "", nil, "", nil, nil, nil, IndentParentHood, false, 0, true})
+	grammar.addBuiltinTag(&TagDefinition{grammar, "#li", `<li{{if .ID}} id="{{.ID}}"{{end}}{{if .Class}} class="{{.Class}}"{{end}}{{if .Style}} style="{{.Style}}"{{end}}>{{.Content}}</li>`, newString("#ul"), sectionNormal, []string{"#ul", "#ol"}, "", nil, "", nil, nil, nil, TextParentHood, false, 0, false})
+	grammar.addBuiltinTag(&TagDefinition{grammar, "#ol", `<ol{{if .ID}} id="{{.ID}}"{{end}}{{if .Class}} class="{{.Class}}"{{end}}{{if .Style}} style="{{.Style}}"{{end}}>{{.Content}}</ol>`, nil, sectionNormal, []string{ /*"#ul", "#ol"*/ }, "", nil, "", nil, nil, nil, IndentParentHood, false, 0, true})
+	grammar.addBuiltinTag(&TagDefinition{grammar, "#", `<h1{{if .ID}} id="{{.ID}}"{{end}}{{if .Class}} class="{{.Class}}"{{end}}{{if .Style}} style="{{.Style}}"{{end}}>{{.Content}}{{.Anchor}}</h1>`, nil, sectionNormal, []string{}, "", nil, "", nil, nil, nil, TextParentHood, false, 1, true})
+	grammar.addBuiltinTag(&TagDefinition{grammar, "##", `<h2{{if .ID}} id="{{.ID}}"{{end}}{{if .Class}} class="{{.Class}}"{{end}}{{if .Style}} style="{{.Style}}"{{end}}>{{.Content}}{{.Anchor}}</h2>`, nil, sectionNormal, []string{}, "", nil, "", nil, nil, nil, TextParentHood, false, 2, true})
+	grammar.addBuiltinTag(&TagDefinition{grammar, "###", `<h3{{if .ID}} id="{{.ID}}"{{end}}{{if .Class}} class="{{.Class}}"{{end}}{{if .Style}} style="{{.Style}}"{{end}}>{{.Content}}{{.Anchor}}</h3>`, nil, sectionNormal, []string{}, "", nil, "", nil, nil, nil, TextParentHood, false, 3, true})
+	grammar.addBuiltinTag(&TagDefinition{grammar, "####", `<h4{{if .ID}} id="{{.ID}}"{{end}}{{if .Class}} class="{{.Class}}"{{end}}{{if .Style}} style="{{.Style}}"{{end}}>{{.Content}}{{.Anchor}}</h4>`, nil, sectionNormal, []string{}, "", nil, "", nil, nil, nil, TextParentHood, false, 4, true})
+	grammar.addBuiltinTag(&TagDefinition{grammar, "#table", `<table{{if .ID}} id="{{.ID}}"{{end}}{{if .Class}} class="{{.Class}}"{{end}}{{if .Style}} style="{{.Style}}"{{end}}>{{with .TableCaption}}<caption>{{.}}</caption>{{end}}{{.Content}}</table>`, nil, sectionTable, []string{}, "Table", nil, "", nil, nil, createConfig("Table"), NoParentHood, false, 0, true})
+	grammar.addBuiltinTag(&TagDefinition{grammar, "#tbody", `<tbody{{if .ID}} id="{{.ID}}"{{end}}{{if .Class}} class="{{.Class}}"{{end}}{{if .Style}} style="{{.Style}}"{{end}}>{{.Content}}</tbody>`, newString("#table"), sectionTable, []string{"#table", "#pie", "#chart", "#timeline"}, "", nil, "", nil, nil, nil, NoParentHood, false, 0, false})
+	grammar.addBuiltinTag(&TagDefinition{grammar, "#thead", `<thead{{if .ID}} id="{{.ID}}"{{end}}{{if .Class}} class="{{.Class}}"{{end}}{{if .Style}} style="{{.Style}}"{{end}}>{{.Content}}</thead>`, newString("#table"), sectionTable, []string{"#table", "#pie", "#chart", "#timeline"}, "", nil, "", nil, nil, nil, NoParentHood, false, 0, false})
+	grammar.addBuiltinTag(&TagDefinition{grammar, "#tfoot", `<tfoot{{if .ID}} id="{{.ID}}"{{end}}{{if .Class}} class="{{.Class}}"{{end}}{{if .Style}} style="{{.Style}}"{{end}}>{{.Content}}</tfoot>`, newString("#table"), sectionTable, []string{"#table", "#pie", "#chart", "#timeline"}, "", nil, "", nil, nil, nil, NoParentHood, false, 0, false})
+	grammar.addBuiltinTag(&TagDefinition{grammar, "#tbody-row", `<tr{{if .ID}} id="{{.ID}}"{{end}}{{if .Class}} class="{{.Class}}"{{end}}{{if .Style}} style="{{.Style}}"{{end}}>{{.Content}}</tr>`, newString("#tbody"), sectionTable, []string{"#tbody"}, "", nil, "", nil, nil, nil, NoParentHood, false, 0, false})
+	grammar.addBuiltinTag(&TagDefinition{grammar, "#thead-row", `<tr{{if .ID}} id="{{.ID}}"{{end}}{{if .Class}} class="{{.Class}}"{{end}}{{if .Style}} style="{{.Style}}"{{end}}>{{.Content}}</tr>`, newString("#thead"), sectionTable, []string{"#thead"}, "", nil, "", nil, nil, nil, NoParentHood, false, 0, false})
+	grammar.addBuiltinTag(&TagDefinition{grammar, "#tfoot-row", `<tr{{if .ID}} id="{{.ID}}"{{end}}{{if .Class}} class="{{.Class}}"{{end}}{{if .Style}} style="{{.Style}}"{{end}}>{{.Content}}</tr>`, newString("#tfoot"), sectionTable, []string{"#tfoot"}, "", nil, "", nil, nil, nil, NoParentHood, false, 0, false})
+	grammar.addBuiltinTag(&TagDefinition{grammar, "#tbody-cell", `{{if .RowHeader}}<th scope="row"{{else}}<td{{end}}{{if .ID}} id="{{.ID}}"{{end}}{{if .Class}} class="{{.Class}}"{{end}}{{if or .Align .Style}} style="{{if .Align}}text-align:{{.Align}};{{end}}{{.Style}}"{{end}}{{if ne .Colspan 1}} colspan="{{.Colspan}}"{{end}}{{if gt .Rowspan 1}} rowspan="{{.Rowspan}}"{{end}}>{{.Content}}{{if .RowHeader}}</th>{{else}}</td>{{end}}`, newString("#tbody-row"), sectionTable, []string{"#tbody-row"}, "", nil, "", nil, nil, nil, TextParentHood, false, 0, false})
+	grammar.addBuiltinTag(&TagDefinition{grammar, "#tfoot-cell", `{{if .RowHeader}}<th scope="row"{{else}}<td{{end}}{{if .ID}} id="{{.ID}}"{{end}}{{if .Class}} class="{{.Class}}"{{end}}{{if or .Align .Style}} style="{{if .Align}}text-align:{{.Align}};{{end}}{{.Style}}"{{end}}{{if ne .Colspan 1}} colspan="{{.Colspan}}"{{end}}{{if gt .Rowspan 1}} rowspan="{{.Rowspan}}"{{end}}>{{.Content}}{{if .RowHeader}}</th>{{else}}</td>{{end}}`, newString("#tfoot-row"), sectionTable, []string{"#tfoot-row"}, "", nil, "", nil, nil, nil, TextParentHood, false, 0, false})
+	grammar.addBuiltinTag(&TagDefinition{grammar, "#thead-cell", `<th{{if .ID}} id="{{.ID}}"{{end}}{{if .Class}} class="{{.Class}}"{{end}}{{if or .Align .Style}} style="{{if .Align}}text-align:{{.Align}};{{end}}{{.Style}}"{{end}}{{if ne .Colspan 1}} colspan="{{.Colspan}}"{{end}}{{if gt .Rowspan 1}} rowspan="{{.Rowspan}}"{{end}}>{{.Content}}</th>`, newString("#thead-row"), sectionTable, []string{"#thead-row"}, "", nil, "", nil, nil, nil, TextParentHood, false, 0, false})
+	grammar.addBuiltinTag(&TagDefinition{grammar, "#code", `{{if .CodeTitle}}<div class="code-title">{{.CodeTitle}}</div>{{end}}<pre{{if .ID}} id="{{.ID}}"{{end}}{{if .HasClass "nosyntax"}} class="{{.Class}}"{{else if .Highlighted}} class="highlight {{.Class}}"{{else}} class="prettyprint {{.Class}}"{{end}}{{if or .Style .HighlightStyle}} style="{{.HighlightStyle}}{{.Style}}"{{end}}>{{.Content}}</pre>`, nil, sectionCode, []string{}, "Sample", nil, "class", nil, nil, createConfig("Sample"), TextParentHood, false, 0, true})
+	grammar.addBuiltinTag(&TagDefinition{grammar, "#math", `<div{{if .ID}} id="{{.ID}}"{{end}}{{if .Class}} class="{{.Class}}"{{end}}{{if .Style}} style="{{.Style}}"{{end}}>{{with .MathML}}{{.}}{{else}}<span class="math">\[{{.Content}}\]</span>{{end}}</div>`, nil, sectionMath, []string{}, "Equation", nil, "", nil, nil, createConfig("Equation"), TextParentHood, false, 0, true})
+	grammar.addBuiltinTag(&TagDefinition{grammar, "#bib", `<p{{if .ID}} id="{{.ID}}"{{end}} class="bibentry {{.Class}}"{{if .Style}} style="{{.Style}}"{{end}}>[{{.Counters.bib}}] {{.Content}}</p>`, nil, sectionNormal, []string{}, "bib", nil, "label", nil, nil, nil, TextParentHood, false, 0, true})
+	// #include is replaced by the content of the included file while parsing.
+	grammar.addBuiltinTag(&TagDefinition{grammar, "#include", ``, nil, sectionNormal, []string{}, "", nil, "src", nil, nil, nil, TextParentHood, false, 0, true})
+	// #fn defines a footnote. It is rendered by #footnotes or at the end of the document.
+	grammar.addBuiltinTag(&TagDefinition{grammar, "#fn", ``, nil, sectionNormal, []string{}, "", nil, "label", nil, nil, nil, TextParentHood, false, 0, true})
+	grammar.addBuiltinTag(&TagDefinition{grammar, "#footnotes", `{{.Content}}`, nil, sectionNormal, []string{}, "", nil, "", nil, nil, nil, NoParentHood, false, 0, true})
+	// #more marks the end of the page summary and generates no HTML.
+	grammar.addBuiltinTag(&TagDefinition{grammar, "#more", ``, nil, sectionNormal, []string{}, "", nil, "", nil, nil, nil, NoParentHood, false, 0, true})
+	grammar.addBuiltinTag(&TagDefinition{grammar, "#pie", `<div{{if .ID}} id="{{.ID}}"{{end}}{{if .Class}} class="{{.Class}}"{{end}}{{if .Style}} style="{{.Style}}"{{end}}>{{.Content}}</div>`, nil, sectionTable, []string{}, "Chart", nil, "", nil, nil, createConfig("Chart"), NoParentHood, false, 0, true})
+	grammar.addBuiltinTag(&TagDefinition{grammar, "#chart", `<div{{if .ID}} id="{{.ID}}"{{end}}{{if .Class}} class="{{.Class}}"{{end}}{{if .Style}} style="{{.Style}}"{{end}}>{{.Content}}</div>`, nil, sectionTable, []string{}, "Chart", nil, "", nil, nil, createConfig("Chart"), NoParentHood, false, 0, true})
+	grammar.addBuiltinTag(&TagDefinition{grammar, "#timeline", `<div{{if .ID}} id="{{.ID}}"{{end}}{{if .Class}} class="{{.Class}}"{{end}}{{if .Style}} style="{{.Style}}"{{end}}>{{.Content}}</div>`, nil, sectionTable, []string{}, "Chart", nil, "", nil, nil, createConfig("Chart"), NoParentHood, false, 0, true})
+	grammar.addBuiltinTag(&TagDefinition{grammar, "#caption", `<div{{if .ID}} id="{{.ID}}"{{end}} class="caption {{.Class}}"{{if .Style}} style="{{.Style}}"{{end}}>{{if .PrevSibling}}{{if and .PrevSibling.TagParams.caption .PrevSibling.TagParams.counter}}<span class="{{.PrevSibling.TagName}}-counter counter">{{.PrevSibling.TagParams.caption}} {{index .Counters .PrevSibling.TagParams.counter}}:</span> {{end}}{{end}}{{.Content}}</div>`, nil, sectionNormal, []string{}, "", nil, "", nil, nil, nil, TextParentHood, false, 0, true})
+	grammar.addBuiltinTag(&TagDefinition{grammar, ">", `<blockquote{{if .ID}} id="{{.ID}}"{{end}}{{if .Class}} class="{{.Class}}"{{end}}{{if .Style}} style="{{.Style}}"{{end}}>{{.Content}}</blockquote>`, nil, sectionNormal, []string{}, "", nil, "", nil, nil, nil, IndentParentHood, false, 0, true})
+	grammar.addBuiltinTag(&TagDefinition{grammar, "#dl", `<dl{{if .ID}} id="{{.ID}}"{{end}}{{if .Class}} class="{{.Class}}"{{end}}{{if .Style}} style="{{.Style}}"{{end}}>{{.Content}}</dl>`, nil, sectionNormal, []string{}, "", nil, "", nil, nil, nil, IndentParentHood, false, 0, true})
+	grammar.addBuiltinTag(&TagDefinition{grammar, "#dt", `<dt{{if .ID}} id="{{.ID}}"{{end}}{{if .Class}} class="{{.Class}}"{{end}}{{if .Style}} style="{{.Style}}"{{end}}>{{.Content}}</dt>`, newString("#dl"), sectionNormal, []string{"#dl"}, "", nil, "", nil, nil, nil, TextParentHood, false, 0, false})
+	grammar.addBuiltinTag(&TagDefinition{grammar, "#dd", `<dd{{if .ID}} id="{{.ID}}"{{end}}{{if .Class}} class="{{.Class}}"{{end}}{{if .Style}} style="{{.Style}}"{{end}}>{{.Content}}</dd>`, newString("#dl"), sectionNormal, []string{"#dl"}, "", nil, "", nil, nil, nil, IndentParentHood, false, 0, false})
+
+	// The HTML of these entities is generated by `HTMLGenerator.innerText`.
+	grammar.addBuiltinEntity(&EntityDefinition{Name: "a"})
+	grammar.addBuiltinEntity(&EntityDefinition{Name: "ref"})
+	grammar.addBuiltinEntity(&EntityDefinition{Name: "img"})
+	grammar.addBuiltinEntity(&EntityDefinition{Name: "bib"})
+	grammar.addBuiltinEntity(&EntityDefinition{Name: "fn", Counter: "fn"})
+	grammar.addBuiltinEntity(&EntityDefinition{Name: "progress"})
+	grammar.addBuiltinEntity(&EntityDefinition{Name: "br"})
 }
 
 func (grammar *Grammar) addCustomTag(tag *TagDefinition) error {
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"html"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// HighlightToken is a piece of source code of a certain kind, e.g. "keyword", "string" or "comment".
+// Text without a special meaning has an empty kind.
+type HighlightToken struct {
+	Kind string
+	Text string
+}
+
+// Lexer splits source code into tokens for syntax highlighting.
+type Lexer interface {
+	Tokenize(code string) []HighlightToken
+}
+
+// lexers holds all lexers indexed by the names of their language, e.g. "go" or "golang".
+var lexers = make(map[string]Lexer)
+
+// RegisterLexer makes a lexer available for #code blocks which use one of the `names` as class.
+func RegisterLexer(lexer Lexer, names ...string) {
+	for _, name := range names {
+		lexers[name] = lexer
+	}
+}
+
+// lexRule matches a token at the current position of a `ruleLexer`.
+type lexRule struct {
+	re *regexp.Regexp
+	// Either a single kind for the entire match or one kind per submatch.
+	// The kind "ident" is replaced by "keyword", "builtin" or the empty kind, depending on the lexer's word lists.
+	kinds []string
+	// State to enter after the match, "#pop" to return to the previous state, or the empty string to stay.
+	next string
+	// If true, the rule matches at the beginning of a line only.
+	lineStart bool
+}
+
+// ruleLexer is a lexer driven by regular expressions.
+// The rules of the current state are tried in order and the first match determines the token.
+type ruleLexer struct {
+	states   map[string][]lexRule
+	keywords map[string]bool
+	builtins map[string]bool
+}
+
+// rule creates a lexRule matching `pattern` at the current position.
+func rule(pattern string, next string, kinds ...string) lexRule {
+	return lexRule{re: regexp.MustCompile(`\A(?:` + pattern + `)`), kinds: kinds, next: next}
+}
+
+// lineRule creates a lexRule matching `pattern` at the beginning of a line.
+func lineRule(pattern string, next string, kinds ...string) lexRule {
+	r := rule(pattern, next, kinds...)
+	r.lineStart = true
+	return r
+}
+
+func wordSet(words string) map[string]bool {
+	set := make(map[string]bool)
+	for _, w := range strings.Fields(words) {
+		set[w] = true
+	}
+	return set
+}
+
+// Tokenize implements the Lexer interface.
+func (l *ruleLexer) Tokenize(code string) []HighlightToken {
+	var tokens []HighlightToken
+	add := func(kind string, text string) {
+		if kind == "ident" {
+			if l.keywords[text] {
+				kind = "keyword"
+			} else if l.builtins[text] {
+				kind = "builtin"
+			} else {
+				kind = ""
+			}
+		}
+		if len(tokens) > 0 && tokens[len(tokens)-1].Kind == kind {
+			tokens[len(tokens)-1].Text += text
+			return
+		}
+		tokens = append(tokens, HighlightToken{Kind: kind, Text: text})
+	}
+	stack := []string{"root"}
+	for pos := 0; pos < len(code); {
+		matched := false
+		for _, r := range l.states[stack[len(stack)-1]] {
+			if r.lineStart && pos > 0 && code[pos-1] != '\n' {
+				continue
+			}
+			m := r.re.FindStringSubmatchIndex(code[pos:])
+			if m == nil || m[1] == 0 {
+				continue
+			}
+			if len(r.kinds) == 1 {
+				add(r.kinds[0], code[pos:pos+m[1]])
+			} else {
+				for i, kind := range r.kinds {
+					if start, end := m[2+2*i], m[3+2*i]; start >= 0 && end > start {
+						add(kind, code[pos+start:pos+end])
+					}
+				}
+			}
+			pos += m[1]
+			if r.next == "#pop" {
+				if len(stack) > 1 {
+					stack = stack[:len(stack)-1]
+				}
+			} else if r.next != "" {
+				stack = append(stack, r.next)
+			}
+			matched = true
+			break
+		}
+		if !matched {
+			_, w := utf8.DecodeRuneInString(code[pos:])
+			add("", code[pos:pos+w])
+			pos += w
+		}
+	}
+	return tokens
+}
+
+// highlightThemes maps theme names to the CSS styles of token kinds.
+// The kind "" holds the style of the entire code block.
+var highlightThemes = map[string]map[string]string{
+	"github": {
+		"":         "background:#f6f8fa;color:#24292e",
+		"keyword":  "color:#d73a49",
+		"builtin":  "color:#6f42c1",
+		"string":   "color:#032f62",
+		"number":   "color:#005cc5",
+		"comment":  "color:#6a737d;font-style:italic",
+		"tag":      "color:#22863a",
+		"attr":     "color:#6f42c1",
+		"property": "color:#005cc5",
+		"variable": "color:#e36209",
+		// Code annotations
+		"lineno":    "color:#959da5;user-select:none",
+		"line-mark": "display:inline-block;width:100%;background:#fffbdd",
+		"line-add":  "display:inline-block;width:100%;background:#e6ffed",
+		"line-del":  "display:inline-block;width:100%;background:#ffeef0",
+		"callout":   "display:inline-block;min-width:1.2em;border-radius:50%;background:#0366d6;color:#fff;text-align:center;font-size:.8em;font-style:normal",
+	},
+	"monokai": {
+		"":         "background:#272822;color:#f8f8f2",
+		"keyword":  "color:#f92672",
+		"builtin":  "color:#66d9ef",
+		"string":   "color:#e6db74",
+		"number":   "color:#ae81ff",
+		"comment":  "color:#75715e;font-style:italic",
+		"tag":      "color:#f92672",
+		"attr":     "color:#a6e22e",
+		"property": "color:#a6e22e",
+		"variable": "color:#fd971f",
+		// Code annotations
+		"lineno":    "color:#90908a;user-select:none",
+		"line-mark": "display:inline-block;width:100%;background:#49483e",
+		"line-add":  "display:inline-block;width:100%;background:#2b3d2b",
+		"line-del":  "display:inline-block;width:100%;background:#4a2b2b",
+		"callout":   "display:inline-block;min-width:1.2em;border-radius:50%;background:#66d9ef;color:#272822;text-align:center;font-size:.8em;font-style:normal",
+	},
+}
+
+// defaultHighlightTheme is used unless page.yaml specifies "HighlightTheme".
+const defaultHighlightTheme = "github"
+
+// highlighting returns the theme and output of syntax highlighting as specified in page.yaml.
+// A page type inherits unspecified values from its parent page type.
+func (p *pageType) highlighting() (theme string, inline bool) {
+	theme = defaultHighlightTheme
+	themeFound, styleFound := false, false
+	for pt := p; pt != nil; pt = pt.inheritPageType {
+		if pt.highlightTheme != "" && !themeFound {
+			theme = pt.highlightTheme
+			themeFound = true
+		}
+		if pt.highlightStyle != "" && !styleFound {
+			inline = pt.highlightStyle == "inline"
+			styleFound = true
+		}
+	}
+	return
+}
+
+// codeLexer returns the lexer for the language named by a class of the #code node `node`, or nil.
+func codeLexer(node *DocumentNode) Lexer {
+	if node.HasClass("nosyntax") {
+		return nil
+	}
+	for _, c := range strings.Fields(node.Attributes["class"]) {
+		if l, ok := lexers[c]; ok {
+			return l
+		}
+	}
+	for k, v := range node.Attributes {
+		if v == "" {
+			if l, ok := lexers[k]; ok {
+				return l
+			}
+		}
+	}
+	return nil
+}
+
+// highlight returns the HTML of `code` with a span for every token that has a kind.
+// Depending on the page type, the spans have CSS classes like "hl-keyword" or inline styles of the theme.
+func (gen *HTMLGenerator) highlight(lexer Lexer, code string) string {
+	return strings.Join(gen.highlightLines(lexer, code), "\n")
+}
+
+// highlightLines returns the highlighted HTML of `code` line by line.
+// Tokens spanning several lines, e.g. block comments, are split such that every line is valid HTML on its own.
+func (gen *HTMLGenerator) highlightLines(lexer Lexer, code string) []string {
+	var lines []string
+	var b strings.Builder
+	for _, t := range lexer.Tokenize(code) {
+		for i, text := range strings.Split(t.Text, "\n") {
+			if i > 0 {
+				lines = append(lines, b.String())
+				b.Reset()
+			}
+			if text != "" {
+				b.WriteString(gen.tokenSpan(t.Kind, html.EscapeString(text)))
+			}
+		}
+	}
+	return append(lines, b.String())
+}
+
+// tokenSpan wraps `content` in a span for the space separated token kinds `kinds`.
+// Content without a kind or without a style in the theme is returned as is.
+func (gen *HTMLGenerator) tokenSpan(kinds string, content string) string {
+	if kinds == "" {
+		return content
+	}
+	if !gen.page.HighlightInline {
+		return `<span class="hl-` + strings.Join(strings.Fields(kinds), " hl-") + `">` + content + `</span>`
+	}
+	theme := highlightThemes[gen.page.HighlightTheme]
+	var styles []string
+	for _, kind := range strings.Fields(kinds) {
+		if style := theme[kind]; style != "" {
+			styles = append(styles, style)
+		}
+	}
+	if len(styles) == 0 {
+		return content
+	}
+	return `<span style="` + strings.Join(styles, ";") + `">` + content + `</span>`
+}
+
+// Highlighted returns true if the code of a #code node is highlighted or annotated by mates.
+// The built-in #code template uses it to decide between server-side and JavaScript highlighting.
+func (ctx *NodeContext) Highlighted() bool {
+	return ctx.node != nil && ctx.node.TagDefinition.SectionMode == sectionCode && (codeLexer(ctx.node) != nil || ctx.node.code != nil)
+}
+
+// HighlightStyle returns the CSS style of a highlighted #code block if the page type uses inline styles.
+func (ctx *NodeContext) HighlightStyle() string {
+	if !ctx.gen.page.HighlightInline || !ctx.Highlighted() {
+		return ""
+	}
+	return highlightThemes[ctx.gen.page.HighlightTheme][""] + ";"
+}
+
+// hasHighlightedCode returns true if a document contains #code nodes which are highlighted or annotated by mates.
+func hasHighlightedCode(doc *DocumentNode) bool {
+	for _, node := range doc.DocumentNodes() {
+		if node.TagDefinition.SectionMode == sectionCode && (codeLexer(node) != nil || node.code != nil) {
+			return true
+		}
+	}
+	return false
+}
+
+// HighlightCSS returns a style sheet for the CSS classes of highlighted code, based on the theme of the page type.
+// `Styles` includes it for pages with highlighted code unless the page type uses inline styles.
+func (ctx *PageContext) HighlightCSS() string {
+	theme := highlightThemes[ctx.page.HighlightTheme]
+	var kinds []string
+	for kind := range theme {
+		if kind != "" {
+			kinds = append(kinds, kind)
+		}
+	}
+	sort.Strings(kinds)
+	css := ".highlight{" + theme[""] + "}\n"
+	for _, kind := range kinds {
+		css += ".highlight .hl-" + kind + "{" + theme[kind] + "}\n"
+	}
+	return css
+}
@@ -23,12 +23,37 @@ type Page struct {
 	Resources []*Resource
 	// The file name (and path) of the source file
 	Fname string
+	// True if the page config has no Title and Params["Title"] has been derived from the file name
+	DerivedTitle bool
 	// Slash-separated path to the content-file generated on the file system, like "/recipes/beef/index.html".
 	// This may be the empty string. In this case no content-file has been generated, for example because
 	// the page type has been set to "none".
 	RelURL       string
 	Params       map[string]interface{}
 	PageTypeName string
+	// The values of all tag types assigned to the page, e.g. "Category": ["Beef", "Spicy"].
+	Tags map[string][]string
+	// All files generated for the page, one per output format.
+	// The first output determines `RelURL`.
+	Outputs []*PageOutput
+	// Slash-separated paths of the content files included by the markdown file, e.g. via #include or as data file of a table.
+	// Paths of data files outside of the content directory start with a slash and are relative to the site directory.
+	// The link graph lists them, so that tools can tell which pages to rebuild when one of these files changes.
+	Dependencies []string
+	// Range of heading levels listed in the table of contents
+	TOCFrom int
+	TOCTo   int
+	// Number of words of the automatic summary
+	SummaryLength int
+	// Theme of syntax highlighting and whether it uses inline styles instead of CSS classes
+	HighlightTheme  string
+	HighlightInline bool
+	// True if formulas are converted to MathML at build time instead of being rendered by JavaScript
+	MathML bool
+	// True if charts are rendered as SVG instead of by JavaScript, unless the chart specifies otherwise
+	SVGCharts bool
+	// Settings of the image pipeline or nil if images are not processed
+	Images *imageConfig
 }
 
 // GeneratorError reports an error that occured while generating output.
@@ -44,9 +69,22 @@ type HTMLGenerator struct {
 	options      *Options
 	textTemplate *template.Template
 	pageContext  *PageContext
-	baseHTML     string
-	layouts      []string
-	resolver     ResourceResolver
+	outputs      []*OutputTemplate
+	// The output that is currently being generated or nil.
+	output *OutputTemplate
+	// Partial templates indexed by name
+	partials map[string]string
+	resolver ResourceResolver
+	// All resources of the page indexed by their unique ID. Used to avoid duplicates.
+	resources map[string]*Resource
+	// Resources requested while executing cached partials, see `executePartialCached`.
+	// Resources are only recorded if `recording` is larger than zero.
+	recorded  []*Resource
+	recording int
+	// Ambiguous references for which a warning has been logged
+	warnedRefs map[string]bool
+	// The HTML of the page content once it has been rendered by `PageContext.Content`
+	content *string
 }
 
 func (err GeneratorError) Error() string {
@@ -54,8 +92,10 @@ func (err GeneratorError) Error() string {
 }
 
 // NewHTMLGenerator returns a new HTML generator for a page.
-func NewHTMLGenerator(page *Page, baseHTML string, layouts []string, resolver ResourceResolver, folderContext, siteContext interface{}, options *Options) *HTMLGenerator {
-	gen := &HTMLGenerator{options: options, page: page, baseHTML: baseHTML, layouts: layouts, resolver: resolver}
+// The generator produces one output for each of the `outputs`.
+// The `partials` are available to all templates of the page. This map may be nil.
+func NewHTMLGenerator(page *Page, outputs []*OutputTemplate, partials map[string]string, resolver ResourceResolver, folderContext, siteContext interface{}, options *Options) *HTMLGenerator {
+	gen := &HTMLGenerator{options: options, page: page, outputs: outputs, partials: partials, resolver: resolver, resources: make(map[string]*Resource), warnedRefs: make(map[string]bool)}
 	gen.pageContext = &PageContext{page: page, gen: gen, folderContext: folderContext, siteContext: siteContext}
 	return gen
 }
@@ -74,25 +114,11 @@ func (gen *HTMLGenerator) Page() *Page {
 // Prepare parses and prepares all required templates and determines
 // all required resources.
 func (gen *HTMLGenerator) Prepare() error {
-	// Find all required resources and add them to the file, but do not add duplicates
-	resources := make(map[string]*Resource)
-	addResource := func(r *Resource, add bool) error {
-		err := gen.resolver(r)
-		if err != nil {
-			return err
-		}
-		id := r.UniqueID()
-		if _, ok := resources[id]; !ok {
-			resources[id] = r
-			if add {
-				gen.page.Resources = append(gen.page.Resources, r)
-			}
-		}
-		return nil
-	}
-
 	gen.textTemplate = template.New("__main__").Delims("{{", "}}")
 	fmap := make(template.FuncMap)
+	for name, f := range libraryFuncs {
+		fmap[name] = f
+	}
 	fmap["prefix"] = funcPrefix
 	fmap["hasPrefix"] = funcHasPrefix
 	fmap["initial"] = funcInitial
@@ -101,6 +127,14 @@ func (gen *HTMLGenerator) Prepare() error {
 	fmap["sortBy"] = funcSortBy
 	fmap["hex"] = funcHex
 	fmap["hash"] = funcHash
+	fmap["ref"] = gen.ref
+	fmap["relref"] = gen.relref
+	fmap["markdownify"] = gen.markdownify
+	fmap["markdownifyInline"] = gen.markdownifyInline
+	fmap["partial"] = gen.executePartial
+	fmap["partialCached"] = func(name string, data interface{}, variants ...interface{}) (string, error) {
+		return gen.executePartialCached(name, data, variants)
+	}
 	fmap["resource"] = func(urlString string) (string, error) {
 		u, err := url.Parse(urlString)
 		if err != nil {
@@ -111,18 +145,44 @@ func (gen *HTMLGenerator) Prepare() error {
 		if err != nil {
 			return "", err
 		}
-		addResource(r, true)
+		gen.addResource(r, true)
 		return r.URL.String(), nil
 	}
-	_, err := gen.textTemplate.Funcs(fmap).Parse(gen.baseHTML)
-	if err != nil {
-		return err
-	}
-	for _, l := range gen.layouts {
-		_, err = gen.textTemplate.Parse(l)
+	gen.textTemplate.Funcs(fmap)
+	// Parse the base and layout templates of all output formats.
+	// The first HTML format shares its templates with the tags, entities and styles.
+	// All other formats get a template set of their own, because each base template defines "__main__".
+	for _, o := range gen.outputs {
+		t := gen.textTemplate
+		if !o.Format.isHTML() || gen.sharesTemplates(o) {
+			t = template.New("__main__").Delims("{{", "}}").Funcs(fmap)
+		}
+		_, err := t.Parse(o.Base)
 		if err != nil {
 			return err
 		}
+		for _, l := range o.Layouts {
+			_, err = t.Parse(l)
+			if err != nil {
+				return err
+			}
+		}
+		o.template = t
+	}
+	// Parse all partials. Each template set gets a copy of its own.
+	sets := []*template.Template{gen.textTemplate}
+	for _, o := range gen.outputs {
+		if o.template != gen.textTemplate {
+			sets = append(sets, o.template)
+		}
+	}
+	for name, text := range gen.partials {
+		for _, t := range sets {
+			_, err := t.New("partials/" + name).Parse(text)
+			if err != nil {
+				return &GeneratorError{nil, fmt.Sprintf("Error parsing partial '%v': %v", name, err)}
+			}
+		}
 	}
 
 	// Parse all tag templates
@@ -149,44 +209,89 @@ func (gen *HTMLGenerator) Prepare() error {
 
 	// Resources defined in the page config
 	for _, r := range gen.page.Resources {
-		addResource(r, false)
+		gen.addResource(r, false)
+	}
+	gen.addDocumentResources(gen.page.Document)
+
+	return nil
+}
+
+// addResource resolves `r` and adds it to the resources of the page unless it is a duplicate.
+// If `add` is false, the resource is only remembered, because it is already part of the page resources.
+func (gen *HTMLGenerator) addResource(r *Resource, add bool) error {
+	err := gen.resolver(r)
+	if err != nil {
+		return err
+	}
+	if add && gen.recording > 0 {
+		gen.recorded = append(gen.recorded, r)
+	}
+	id := r.UniqueID()
+	if _, ok := gen.resources[id]; !ok {
+		gen.resources[id] = r
+		if add {
+			gen.page.Resources = append(gen.page.Resources, r)
+		}
 	}
+	return nil
+}
+
+// addDocumentResources adds all resources required by the tags, entities and styles used in `doc`.
+func (gen *HTMLGenerator) addDocumentResources(doc *DocumentNode) {
 	// Resources required by the tags used
-	for _, node := range gen.page.Document.DocumentNodes() {
+	for _, node := range doc.DocumentNodes() {
 		for _, r := range node.TagDefinition.Resources {
-			addResource(r, true)
+			gen.addResource(r, true)
 		}
 	}
 	// Resources required by the entities used
-	for _, e := range gen.page.Document.Entities() {
+	for _, e := range doc.Entities() {
 		for _, r := range e.EntityDefinition.Resources {
-			addResource(r, true)
+			gen.addResource(r, true)
 		}
 	}
 	// Resources required by the styles used
-	for _, s := range gen.page.Document.styles() {
+	for _, s := range doc.styles() {
 		if s.StyleDefinition != nil {
 			for _, r := range s.StyleDefinition.Resources {
-				addResource(r, true)
+				gen.addResource(r, true)
 			}
 		}
 	}
-
-	return nil
 }
 
-// Generate HTML for the given file.
-func (gen *HTMLGenerator) Generate() (string, error) {
-	w := bytes.NewBuffer(nil)
-	err := gen.textTemplate.ExecuteTemplate(w, "__main__", gen.pageContext)
-	if err != nil {
-		return "", &GeneratorError{gen.page.Document, fmt.Sprintf("Error executing template for page '%v': %v", gen.page.Fname, err)}
+// sharesTemplates returns true if an output preceding `o` uses the template set of the tags.
+func (gen *HTMLGenerator) sharesTemplates(o *OutputTemplate) bool {
+	for _, p := range gen.outputs {
+		if p == o {
+			break
+		}
+		if p.template == gen.textTemplate {
+			return true
+		}
 	}
-	return w.String(), nil
+	return false
 }
 
-func (gen *HTMLGenerator) imgSrc(href string) string {
-	return fmt.Sprintf(`src="%v"`, href)
+// Generate the output of the given format for the file.
+func (gen *HTMLGenerator) Generate(format *OutputFormat) (string, error) {
+	for _, o := range gen.outputs {
+		if o.Format != format {
+			continue
+		}
+		gen.output = o
+		w := bytes.NewBuffer(nil)
+		err := o.template.ExecuteTemplate(w, "__main__", gen.pageContext)
+		gen.output = nil
+		if err != nil {
+			return "", &GeneratorError{gen.page.Document, fmt.Sprintf("Error executing %v template for page '%v': %v", format.Name, gen.page.Fname, err)}
+		}
+		// All resources are known now
+		str := strings.Replace(w.String(), scriptsPlaceholder, gen.pageContext.scripts(), -1)
+		str = strings.Replace(str, stylesPlaceholder, gen.pageContext.styles(), -1)
+		return str, nil
+	}
+	return "", &GeneratorError{gen.page.Document, fmt.Sprintf("Page '%v' has no output format %v", gen.page.Fname, format.Name)}
 }
 
 func (gen *HTMLGenerator) executeTemplate(name string, node *DocumentNode) (string, error) {
@@ -239,14 +344,21 @@ func (gen *HTMLGenerator) outerHTML(node *DocumentNode) (string, error) {
 		}
 		html += tmp
 	}
-	return html, nil
+	// Footnotes not listed by a #footnotes tag
+	tmp, err := gen.footnotes(node)
+	if err != nil {
+		return "", err
+	}
+	return html + tmp, nil
 }
 
 func (gen *HTMLGenerator) innerHTML(node *DocumentNode) (string, error) {
-	if node.Tag == "#pie" {
-		return generatePieChart(node), nil
-	} else if node.Tag == "#chart" {
-		return generateLineBarChart(node), nil
+	if node.Tag == "#pie" || node.Tag == "#chart" {
+		return gen.chart(node), nil
+	} else if node.Tag == "#timeline" {
+		return gen.timeline(node)
+	} else if node.Tag == "#footnotes" {
+		return gen.footnotes(node)
 	}
 
 	result, err := gen.innerText(node)
@@ -266,10 +378,10 @@ func (gen *HTMLGenerator) innerHTML(node *DocumentNode) (string, error) {
 }
 
 func (gen *HTMLGenerator) innerTags(node *DocumentNode) (string, error) {
-	if node.Tag == "#pie" {
-		return generatePieChart(node), nil
-	} else if node.Tag == "#chart" {
-		return generateLineBarChart(node), nil
+	if node.Tag == "#pie" || node.Tag == "#chart" {
+		return gen.chart(node), nil
+	} else if node.Tag == "#timeline" {
+		return gen.timeline(node)
 	}
 
 	result := ""
@@ -288,6 +400,17 @@ func (gen *HTMLGenerator) innerText(node NodeWithText) (string, error) {
 	// Get the containing DocumentNode
 	docNode := node.documentNode()
 
+	// Annotated code is rendered line by line
+	if docNode.code != nil && NodeWithText(docNode) == node {
+		code := ""
+		for _, t := range node.TextChildren() {
+			if c, ok := t.(*CodeNode); ok {
+				code += c.Text
+			}
+		}
+		return gen.annotatedCode(docNode, code), nil
+	}
+
 	var result = ""
 	var style = make([]string, 5)
 	var styleLevel = 0
@@ -306,6 +429,8 @@ func (gen *HTMLGenerator) innerText(node NodeWithText) (string, error) {
 			}
 			if docNode.TagDefinition.SectionMode == sectionMath {
 				result += html.EscapeString(t.(*MathNode).Text)
+			} else if str := gen.inlineFormula(t.(*MathNode)); str != "" {
+				result += str
 			} else {
 				result += `<span class="math">\(`
 				result += html.EscapeString(t.(*MathNode).Text)
@@ -317,7 +442,11 @@ func (gen *HTMLGenerator) innerText(node NodeWithText) (string, error) {
 				break
 			}
 			if docNode.TagDefinition.SectionMode == sectionCode {
-				result += html.EscapeString(t.(*CodeNode).Text)
+				if lexer := codeLexer(docNode); lexer != nil {
+					result += gen.highlight(lexer, t.(*CodeNode).Text)
+				} else {
+					result += html.EscapeString(t.(*CodeNode).Text)
+				}
 			} else {
 				result += `<code>`
 				result += html.EscapeString(t.(*CodeNode).Text)
@@ -399,14 +528,25 @@ func (gen *HTMLGenerator) innerText(node NodeWithText) (string, error) {
 			edef := gen.page.Grammar.GetEntity(entity.Name)
 			if entity.Name == "img" {
 				if docNode.TagDefinition.SectionMode == sectionMedia {
-					result += `<li><a class="thumbnail" href="` + entity.Attributes["img"] + `"><img` + class + id + style + ` ` + gen.imgSrc(entity.Attributes["img"]) + `></a></li>`
+					result += `<li><a class="thumbnail" href="` + entity.Value + `"><img` + class + id + style + ` ` + gen.imgSrc(entity.Value, entity.Line, true) + `></a></li>`
 				} else {
-					result += `<img` + class + id + style + ` ` + gen.imgSrc(entity.Attributes["img"]) + `>`
+					result += `<img` + class + id + style + ` ` + gen.imgSrc(entity.Value, entity.Line, false) + `>`
 				}
 			} else if entity.Name == "a" {
-				result += `<a` + class + id + style + ` href="` + entity.Attributes["a"] + `">` + entity.Attributes["a"] + `</a>`
+				result += `<a` + class + id + style + ` href="` + entity.Value + `">` + entity.Value + `</a>`
+			} else if entity.Name == "ref" {
+				href, err := gen.ref(entity.Value)
+				if err != nil {
+					return "", fmt.Errorf("%v:%v: %v", gen.page.Fname, entity.Line, err)
+				}
+				page, anchor, _ := gen.resolveRef(entity.Value)
+				title, err := refText(page, anchor)
+				if err != nil {
+					return "", err
+				}
+				result += `<a` + class + id + style + ` href="` + html.EscapeString(href) + `">` + html.EscapeString(title) + `</a>`
 			} else if entity.Name == "bib" {
-				bibtext := entity.Attributes["bib"]
+				bibtext := entity.Value
 				bibs := strings.Split(bibtext, ",")
 				result += `[`
 				for i, b := range bibs {
@@ -428,9 +568,11 @@ func (gen *HTMLGenerator) innerText(node NodeWithText) (string, error) {
 					}
 				}
 				result += `]`
+			} else if entity.Name == "fn" {
+				result += gen.footnoteRef(entity)
 			} else if entity.Name == "progress" {
 				c := "progress progress-inline"
-				args := strings.Split(entity.Attributes["progress"], ":")
+				args := strings.Split(entity.Value, ":")
 				w := args[0]
 				t := args[0]
 				if len(args) > 1 {
@@ -0,0 +1,504 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"log"
+	"math"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+// imageConfig holds the settings of the image pipeline as specified by "Images" in site.yaml or page.yaml.
+//
+//	Images:
+//	  Widths: 480, 960, 1600
+//	  Sizes: "(max-width: 800px) 100vw, 800px"
+//	  Thumbnail: 320
+//	  Quality: 85
+//	  Cache: .cache/images
+//
+// `Widths` are the widths of the variants listed in the srcset of an image, given as a list or separated by commas. `Sizes` is its sizes attribute.
+// `Thumbnail` is the width of images in media galleries. `Quality` applies to JPEG files.
+// `Cache` is the directory of the site in which processed images are kept between builds. It is allowed in site.yaml only.
+// A page type inherits all settings which it does not specify from its parent page type and finally from site.yaml.
+// Images are processed only if site.yaml or the page type or one of its parents specifies "Images".
+type imageConfig struct {
+	widths    []int
+	sizes     string
+	thumbnail int
+	quality   int
+	cache     string
+	// Processes the images of all pages. Only set for the configuration of a page.
+	pipeline *imagePipeline
+	// True if "Images" has been specified
+	enabled bool
+}
+
+// Defaults of the image pipeline
+const (
+	defaultImageQuality = 85
+	defaultImageCache   = ".cache/images"
+)
+
+func (c *imageConfig) addFromYaml(y interface{}, filename string, site bool) error {
+	m, err := yamlMap("Images", y, filename)
+	if err != nil {
+		return err
+	}
+	c.enabled = true
+	number := func(k string, v interface{}) (int, error) {
+		str, err := yamlString(k, v, filename)
+		if err != nil {
+			return 0, err
+		}
+		n, err := strconv.Atoi(str)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("%v must be a positive number", k)
+		}
+		return n, nil
+	}
+	for k, v := range m {
+		switch k {
+		case "Widths":
+			var list []string
+			list, err = yamlStringOrStrings(k, v, filename)
+			c.widths = nil
+			for _, str := range list {
+				// A single string can list several widths
+				for _, f := range strings.Split(str, ",") {
+					var w int
+					if w, err = number(k, strings.TrimSpace(f)); err != nil {
+						break
+					}
+					c.widths = append(c.widths, w)
+				}
+			}
+			sort.Ints(c.widths)
+		case "Sizes":
+			c.sizes, err = yamlString(k, v, filename)
+			// The value is usually quoted, because it contains colons
+			if len(c.sizes) >= 2 && c.sizes[0] == '"' && c.sizes[len(c.sizes)-1] == '"' {
+				c.sizes = c.sizes[1 : len(c.sizes)-1]
+			}
+		case "Thumbnail":
+			c.thumbnail, err = number(k, v)
+		case "Quality":
+			c.quality, err = number(k, v)
+			if err == nil && c.quality > 100 {
+				err = fmt.Errorf("Quality must be at most 100")
+			}
+		case "Cache":
+			if !site {
+				return fmt.Errorf("%v Images: Cache can only be specified in site.yaml", filename)
+			}
+			c.cache, err = yamlString(k, v, filename)
+		default:
+			return fmt.Errorf("%v Images: unknown attribute %v", filename, k)
+		}
+		if err != nil {
+			return fmt.Errorf("%v Images: %v", filename, err)
+		}
+	}
+	return nil
+}
+
+// imageSettings returns the image settings of pages using this page type, based on the settings `site` of site.yaml.
+// The result is nil if neither site.yaml nor the page types specify "Images".
+func (p *pageType) imageSettings(site *imageConfig, pipeline *imagePipeline) *imageConfig {
+	c := *site
+	var chain []*pageType
+	for pt := p; pt != nil; pt = pt.inheritPageType {
+		chain = append(chain, pt)
+		if pt.images != nil {
+			c.enabled = true
+		}
+	}
+	if !c.enabled {
+		return nil
+	}
+	for i := len(chain) - 1; i >= 0; i-- {
+		if pc := chain[i].images; pc != nil {
+			if pc.widths != nil {
+				c.widths = pc.widths
+			}
+			if pc.sizes != "" {
+				c.sizes = pc.sizes
+			}
+			if pc.thumbnail != 0 {
+				c.thumbnail = pc.thumbnail
+			}
+			if pc.quality != 0 {
+				c.quality = pc.quality
+			}
+		}
+	}
+	if c.quality == 0 {
+		c.quality = defaultImageQuality
+	}
+	c.pipeline = pipeline
+	return &c
+}
+
+// imagePipeline resizes JPEG, PNG and GIF files. The results are kept in a cache directory, named by the hash of the image.
+type imagePipeline struct {
+	cacheFs afero.Fs
+	// Processed images by source path
+	images map[string]*processedImage
+}
+
+// processedImage is an image file of the content and its variants.
+type processedImage struct {
+	// Size as displayed, i.e. after applying the EXIF orientation
+	width, height int
+	orientation   int
+	hash          string
+	format        string
+	// False for animated GIFs, which are not resized
+	resizable bool
+	source    *Resource
+	// Variants by width
+	variants map[int]*imageVariant
+}
+
+// imageVariant is a resized image.
+type imageVariant struct {
+	width, height int
+	// The file in the cache directory
+	cachePath string
+}
+
+func newImagePipeline(cacheFs afero.Fs) *imagePipeline {
+	return &imagePipeline{cacheFs: cacheFs, images: make(map[string]*processedImage)}
+}
+
+// isProcessableImage returns true if the file name has the extension of a JPEG, PNG or GIF file.
+func isProcessableImage(name string) bool {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".jpg", ".jpeg", ".png", ".gif":
+		return true
+	}
+	return false
+}
+
+// load reads the image at `res` and determines its size.
+func (p *imagePipeline) load(res *Resource) (*processedImage, error) {
+	if img, ok := p.images[res.SourcePath]; ok {
+		return img, nil
+	}
+	data, err := afero.ReadFile(res.SourceFs, res.SourcePath)
+	if err != nil {
+		return nil, err
+	}
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	img := &processedImage{width: cfg.Width, height: cfg.Height, orientation: 1, hash: hex.EncodeToString(sum[:]), format: format, resizable: true, source: res, variants: make(map[int]*imageVariant)}
+	switch format {
+	case "jpeg":
+		img.orientation = jpegOrientation(data)
+		if img.orientation >= 5 {
+			img.width, img.height = img.height, img.width
+		}
+	case "gif":
+		g, err := gif.DecodeAll(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		img.resizable = len(g.Image) == 1
+	}
+	p.images[res.SourcePath] = img
+	return img, nil
+}
+
+// variant returns a variant of the image that is `width` pixels wide, generating it unless the cache holds it already.
+func (p *imagePipeline) variant(img *processedImage, width, quality int) (*imageVariant, error) {
+	if v, ok := img.variants[width]; ok {
+		return v, nil
+	}
+	height := int(math.Max(1, math.Round(float64(img.height)*float64(width)/float64(img.width))))
+	ext := map[string]string{"jpeg": ".jpg", "png": ".png", "gif": ".gif"}[img.format]
+	v := &imageVariant{width: width, height: height, cachePath: fmt.Sprintf("%v-%vw-q%v%v", img.hash[:32], width, quality, ext)}
+	if _, err := p.cacheFs.Stat(v.cachePath); os.IsNotExist(err) {
+		data, err := img.resize(width, height, quality)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.cacheFs.MkdirAll("", 0775); err != nil {
+			return nil, err
+		}
+		if err := afero.WriteFile(p.cacheFs, v.cachePath, data, 0664); err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	}
+	img.variants[width] = v
+	return v, nil
+}
+
+// resize decodes the image, scales it to the given size as displayed and encodes it in its original format.
+func (img *processedImage) resize(width, height, quality int) ([]byte, error) {
+	data, err := afero.ReadFile(img.source.SourceFs, img.source.SourcePath)
+	if err != nil {
+		return nil, err
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	b := src.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), src, b.Min, draw.Src)
+	// Scale first and rotate the smaller image
+	w, h := width, height
+	if img.orientation >= 5 {
+		w, h = h, w
+	}
+	result := orientImage(resizeImage(rgba, w, h), img.orientation)
+	var buf bytes.Buffer
+	switch img.format {
+	case "jpeg":
+		err = jpeg.Encode(&buf, result, &jpeg.Options{Quality: quality})
+	case "png":
+		err = png.Encode(&buf, result)
+	case "gif":
+		// Keep the colors of the original
+		palette := src.(*image.Paletted).Palette
+		dst := image.NewPaletted(result.Bounds(), palette)
+		draw.FloydSteinberg.Draw(dst, dst.Bounds(), result, image.Point{})
+		err = gif.Encode(&buf, dst, nil)
+	}
+	return buf.Bytes(), err
+}
+
+// resampleWeight is the share of a source pixel in a pixel of the scaled image.
+type resampleWeight struct {
+	dst    int
+	weight float32
+}
+
+// resampleWeights returns for each source pixel the pixels of the scaled image which it covers, when shrinking `srcLen` pixels to `dstLen` pixels.
+func resampleWeights(srcLen, dstLen int) [][]resampleWeight {
+	result := make([][]resampleWeight, srcLen)
+	scale := float64(srcLen) / float64(dstLen)
+	for i := 0; i < dstLen; i++ {
+		from, to := float64(i)*scale, float64(i+1)*scale
+		for j := int(from); j < srcLen && float64(j) < to; j++ {
+			if overlap := math.Min(to, float64(j+1)) - math.Max(from, float64(j)); overlap > 0 {
+				result[j] = append(result[j], resampleWeight{i, float32(overlap / scale)})
+			}
+		}
+	}
+	return result
+}
+
+// resizeImage shrinks an image by averaging the source pixels covered by each pixel of the result.
+func resizeImage(src *image.RGBA, w, h int) *image.RGBA {
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+	xw, yw := resampleWeights(sw, w), resampleWeights(sh, h)
+	acc := make([]float32, w*h*4)
+	row := make([]float32, w*4)
+	for y := 0; y < sh; y++ {
+		for i := range row {
+			row[i] = 0
+		}
+		pix := src.Pix[y*src.Stride:]
+		for x := 0; x < sw; x++ {
+			for _, cx := range xw[x] {
+				for c := 0; c < 4; c++ {
+					row[cx.dst*4+c] += float32(pix[x*4+c]) * cx.weight
+				}
+			}
+		}
+		for _, cy := range yw[y] {
+			a := acc[cy.dst*w*4:]
+			for i, v := range row {
+				a[i] += v * cy.weight
+			}
+		}
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for i, v := range acc {
+		dst.Pix[i] = uint8(math.Min(255, float64(v)+0.5))
+	}
+	return dst
+}
+
+// orientImage rotates and flips an image as specified by an EXIF orientation.
+func orientImage(src *image.RGBA, orientation int) *image.RGBA {
+	if orientation < 2 || orientation > 8 {
+		return src
+	}
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			// The source pixel shown at x, y
+			sx, sy := x, y
+			switch orientation {
+			case 2:
+				sx = w - 1 - x
+			case 3:
+				sx, sy = w-1-x, h-1-y
+			case 4:
+				sy = h - 1 - y
+			case 5:
+				sx, sy = y, x
+			case 6:
+				sx, sy = y, h-1-x
+			case 7:
+				sx, sy = w-1-y, h-1-x
+			case 8:
+				sx, sy = w-1-y, x
+			}
+			copy(dst.Pix[y*dst.Stride+x*4:y*dst.Stride+x*4+4], src.Pix[sy*src.Stride+sx*4:])
+		}
+	}
+	return dst
+}
+
+// jpegOrientation returns the EXIF orientation of a JPEG file, or 1 if there is none.
+func jpegOrientation(data []byte) int {
+	for i := 2; i+4 <= len(data) && data[i] == 0xff; {
+		marker := data[i+1]
+		size := int(binary.BigEndian.Uint16(data[i+2:]))
+		// The image data starts at the SOS marker
+		if marker == 0xda || i+2+size > len(data) {
+			break
+		}
+		seg := data[i+4 : i+2+size]
+		if marker == 0xe1 && len(seg) > 14 && string(seg[:6]) == "Exif\x00\x00" {
+			tiff := seg[6:]
+			var order binary.ByteOrder = binary.BigEndian
+			if string(tiff[:2]) == "II" {
+				order = binary.LittleEndian
+			}
+			ifd := int(order.Uint32(tiff[4:]))
+			if ifd+2 > len(tiff) {
+				break
+			}
+			n := int(order.Uint16(tiff[ifd:]))
+			for k := 0; k < n; k++ {
+				e := ifd + 2 + k*12
+				if e+12 > len(tiff) {
+					break
+				}
+				if order.Uint16(tiff[e:]) == 0x0112 {
+					if o := int(order.Uint16(tiff[e+8:])); o >= 1 && o <= 8 {
+						return o
+					}
+				}
+			}
+			break
+		}
+		i += 2 + size
+	}
+	return 1
+}
+
+// imgSrc returns the attributes of an <img> showing the image at `href`.
+// Images of the content are copied to the output and resized as specified by the image settings of the page.
+// In media galleries, `thumbnail` is true and the original image is copied as well, because the thumbnail links to it.
+func (gen *HTMLGenerator) imgSrc(href string, line int, thumbnail bool) string {
+	plain := fmt.Sprintf(`src="%v"`, href)
+	config := gen.page.Images
+	u, err := url.Parse(href)
+	if config == nil || err != nil || u.IsAbs() || u.Host != "" || u.Path == "" || !isProcessableImage(u.Path) {
+		return plain
+	}
+	// Resolve the path relative to the page
+	p := u.Path
+	if !strings.HasPrefix(p, "/") {
+		p = path.Join("/", path.Dir(filepath.ToSlash(gen.page.Fname)), p)
+	}
+	res := &Resource{Type: ResourceTypeUnknown, URL: &url.URL{Path: p}}
+	if err := gen.resolver(res); err != nil || res.SourceFs == nil {
+		return plain
+	}
+	img, err := config.pipeline.load(res)
+	if err != nil {
+		// Images which are not part of the content are left alone
+		if !os.IsNotExist(err) {
+			log.Printf("%v:%v: Cannot process image %v: %v", gen.page.Fname, line, href, err)
+		}
+		return plain
+	}
+
+	// The candidates of the srcset are smaller than the original, followed by the original itself.
+	// Thumbnails list the original only if a width exceeds it.
+	type candidate struct {
+		href          string
+		width, height int
+	}
+	var candidates []candidate
+	thumb := thumbnail && config.thumbnail != 0
+	widths := config.widths
+	if thumb {
+		widths = []int{config.thumbnail, 2 * config.thumbnail}
+	}
+	original := !thumb || len(widths) == 0 || !img.resizable
+	for _, w := range widths {
+		if w >= img.width || !img.resizable {
+			original = true
+			break
+		}
+		v, err := config.pipeline.variant(img, w, config.quality)
+		if err != nil {
+			log.Printf("%v:%v: Cannot resize image %v: %v", gen.page.Fname, line, href, err)
+			return plain
+		}
+		name := fmt.Sprintf("%v-%vw-%v%v", strings.TrimSuffix(path.Base(u.Path), path.Ext(u.Path)), w, img.hash[:8], path.Ext(v.cachePath))
+		dest := path.Join(path.Dir(res.DestPath), name)
+		gen.addResource(&Resource{Type: ResourceTypeUnknown, URL: &url.URL{Path: dest}, SourceFs: config.pipeline.cacheFs, SourcePath: v.cachePath, DestPath: dest, Resolved: true}, true)
+		candidates = append(candidates, candidate{path.Join(path.Dir(u.Path), name), v.width, v.height})
+	}
+	if original {
+		candidates = append(candidates, candidate{u.Path, img.width, img.height})
+	}
+	if original || thumbnail {
+		gen.addResource(res, true)
+	}
+
+	// Browsers ignoring the srcset show the largest image, or the smallest one in case of thumbnails
+	src := candidates[len(candidates)-1]
+	if thumb {
+		src = candidates[0]
+	}
+	attrs := fmt.Sprintf(`src="%v"`, html.EscapeString(src.href))
+	if len(candidates) > 1 {
+		var set []string
+		for _, c := range candidates {
+			set = append(set, fmt.Sprintf("%v %vw", html.EscapeString(c.href), c.width))
+		}
+		attrs += fmt.Sprintf(` srcset="%v"`, strings.Join(set, ", "))
+		if thumb {
+			attrs += fmt.Sprintf(` sizes="%vpx"`, config.thumbnail)
+		} else if config.sizes != "" {
+			attrs += fmt.Sprintf(` sizes="%v"`, html.EscapeString(config.sizes))
+		}
+	}
+	return attrs + fmt.Sprintf(` width="%v" height="%v" loading="lazy"`, src.width, src.height)
+}
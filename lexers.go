@@ -0,0 +1,188 @@
+package main
+
+// Lexers for the languages highlighted by mates out of the box.
+// Further languages can be added with `RegisterLexer`.
+
+func init() {
+	RegisterLexer(goLexer(), "go", "golang")
+	RegisterLexer(jsLexer(), "js", "javascript", "ts", "typescript")
+	RegisterLexer(pythonLexer(), "python", "py")
+	RegisterLexer(shellLexer(), "bash", "sh", "shell", "zsh")
+	RegisterLexer(yamlLexer(), "yaml", "yml")
+	RegisterLexer(jsonLexer(), "json")
+	RegisterLexer(htmlLexer(), "html", "xml")
+	RegisterLexer(cssLexer(), "css")
+}
+
+const (
+	identPattern        = `[A-Za-z_$][A-Za-z0-9_$]*`
+	numberPattern       = `0[xX][0-9a-fA-F_]+|[0-9][0-9_]*(?:\.[0-9_]+)?(?:[eE][+-]?[0-9]+)?`
+	doubleQuotedPattern = `"(?:[^"\\\n]|\\.)*"?`
+	singleQuotedPattern = `'(?:[^'\\\n]|\\.)*'?`
+	blockCommentPattern = `/\*(?s:.*?)(?:\*/|$)`
+)
+
+func goLexer() Lexer {
+	return &ruleLexer{
+		states: map[string][]lexRule{
+			"root": {
+				rule(`//[^\n]*`, "", "comment"),
+				rule(blockCommentPattern, "", "comment"),
+				rule(doubleQuotedPattern, "", "string"),
+				rule("`[^`]*`?", "", "string"),
+				rule(singleQuotedPattern, "", "string"),
+				rule(numberPattern, "", "number"),
+				rule(identPattern, "", "ident"),
+			},
+		},
+		keywords: wordSet(`break case chan const continue default defer else fallthrough for func go goto if import
+			interface map package range return select struct switch type var true false nil iota`),
+		builtins: wordSet(`append cap close complex copy delete imag len make new panic print println real recover
+			bool byte complex64 complex128 error float32 float64 int int8 int16 int32 int64 rune string
+			uint uint8 uint16 uint32 uint64 uintptr any`),
+	}
+}
+
+func jsLexer() Lexer {
+	return &ruleLexer{
+		states: map[string][]lexRule{
+			"root": {
+				rule(`//[^\n]*`, "", "comment"),
+				rule(blockCommentPattern, "", "comment"),
+				rule(doubleQuotedPattern, "", "string"),
+				rule(singleQuotedPattern, "", "string"),
+				rule("`(?:[^`\\\\]|\\\\.)*`?", "", "string"),
+				rule(numberPattern, "", "number"),
+				rule(identPattern, "", "ident"),
+			},
+		},
+		keywords: wordSet(`async await break case catch class const continue debugger default delete do else export
+			extends finally for function if import in instanceof let new of return static super switch this throw
+			try typeof var void while with yield true false null undefined interface type enum implements`),
+		builtins: wordSet(`Array Boolean Date Error JSON Map Math Number Object Promise RegExp Set String Symbol
+			console document window parseInt parseFloat require module exports`),
+	}
+}
+
+func pythonLexer() Lexer {
+	return &ruleLexer{
+		states: map[string][]lexRule{
+			"root": {
+				rule(`#[^\n]*`, "", "comment"),
+				rule(`[rRbBfFuU]{0,2}"""(?s:.*?)(?:"""|$)`, "", "string"),
+				rule(`[rRbBfFuU]{0,2}'''(?s:.*?)(?:'''|$)`, "", "string"),
+				rule(`[rRbBfFuU]{0,2}`+doubleQuotedPattern, "", "string"),
+				rule(`[rRbBfFuU]{0,2}`+singleQuotedPattern, "", "string"),
+				rule(`@[A-Za-z_][\w.]*`, "", "builtin"),
+				rule(numberPattern, "", "number"),
+				rule(`[A-Za-z_][A-Za-z0-9_]*`, "", "ident"),
+			},
+		},
+		keywords: wordSet(`and as assert async await break class continue def del elif else except finally for from
+			global if import in is lambda nonlocal not or pass raise return try while with yield True False None`),
+		builtins: wordSet(`abs all any bool bytes dict dir enumerate filter float format getattr hasattr int isinstance
+			len list map max min object open print range repr reversed round set sorted str sum super tuple type zip self`),
+	}
+}
+
+func shellLexer() Lexer {
+	return &ruleLexer{
+		states: map[string][]lexRule{
+			"root": {
+				rule(`#[^\n]*`, "", "comment"),
+				rule(`\$\{[^}\n]*\}?|\$[A-Za-z_][A-Za-z0-9_]*|\$[0-9#?@*$!-]`, "", "variable"),
+				rule(`"`, "dq", "string"),
+				rule(`'[^']*'?`, "", "string"),
+				rule(`[0-9]+\b`, "", "number"),
+				rule(`[A-Za-z_][A-Za-z0-9_-]*`, "", "ident"),
+				rule(`[^\s$"'#A-Za-z0-9_]+|\s+`, "", ""),
+			},
+			// Inside of double quotes variables are expanded
+			"dq": {
+				rule(`"`, "#pop", "string"),
+				rule(`\$\{[^}\n]*\}?|\$[A-Za-z_][A-Za-z0-9_]*|\$[0-9#?@*$!-]`, "", "variable"),
+				rule(`(?:[^"\\$]|\\.)+|\$`, "", "string"),
+			},
+		},
+		keywords: wordSet(`if then else elif fi for while until do done case esac function in return select time`),
+		builtins: wordSet(`echo cd export local read set unset source exit alias eval exec printf test shift trap
+			pwd kill wait true false`),
+	}
+}
+
+func yamlLexer() Lexer {
+	return &ruleLexer{
+		states: map[string][]lexRule{
+			"root": {
+				lineRule(`([ \t]*(?:-[ \t]+)*)([^\s#:"'-][^\n:#]*?|"[^"\n]*"|'[^'\n]*')([ \t]*:)([ \t]+|(?m:$))`, "", "", "property", "", ""),
+				rule(`#[^\n]*`, "", "comment"),
+				rule(doubleQuotedPattern, "", "string"),
+				rule(`'(?:[^'\n]|'')*'?`, "", "string"),
+				rule(`(?:true|false|yes|no|null)\b|~`, "", "keyword"),
+				rule(`-?(?:`+numberPattern+`)\b`, "", "number"),
+				rule(`[&*][\w-]+|!!?[\w-]*`, "", "builtin"),
+				rule(`[^\s#"']+|[ \t]+|\n`, "", ""),
+			},
+		},
+	}
+}
+
+func jsonLexer() Lexer {
+	return &ruleLexer{
+		states: map[string][]lexRule{
+			"root": {
+				rule(`("(?:[^"\\\n]|\\.)*")(\s*:)`, "", "property", ""),
+				rule(doubleQuotedPattern, "", "string"),
+				rule(`-?(?:`+numberPattern+`)`, "", "number"),
+				rule(`true|false|null`, "", "keyword"),
+			},
+		},
+	}
+}
+
+func htmlLexer() Lexer {
+	return &ruleLexer{
+		states: map[string][]lexRule{
+			"root": {
+				rule(`<!--(?s:.*?)(?:-->|$)`, "", "comment"),
+				rule(`<![^>]*>?`, "", "keyword"),
+				rule(`</?[A-Za-z][\w:.-]*`, "tag", "tag"),
+				rule(`&#?\w+;`, "", "builtin"),
+				rule(`[^<&]+`, "", ""),
+			},
+			// Inside of a start or end tag
+			"tag": {
+				rule(`/?>`, "#pop", "tag"),
+				rule(`([A-Za-z_:@][\w:.@-]*)(\s*=\s*)?`, "", "attr", ""),
+				rule(`"[^"]*"?|'[^']*'?`, "", "string"),
+				rule(`\s+`, "", ""),
+			},
+		},
+	}
+}
+
+func cssLexer() Lexer {
+	return &ruleLexer{
+		states: map[string][]lexRule{
+			"root": {
+				rule(blockCommentPattern, "", "comment"),
+				rule(`@[\w-]+`, "", "keyword"),
+				rule(`\{`, "block", ""),
+				rule(`[.#][\w-]+`, "", "attr"),
+				rule(`[A-Za-z][\w-]*`, "", "tag"),
+				rule(`"[^"]*"?|'[^']*'?`, "", "string"),
+			},
+			// Declarations between braces
+			"block": {
+				rule(blockCommentPattern, "", "comment"),
+				rule(`\}`, "#pop", ""),
+				rule(`([\w-]+)(\s*:)`, "", "property", ""),
+				rule(`#[0-9a-fA-F]{3,8}\b`, "", "number"),
+				rule(`-?[0-9]*\.?[0-9]+(?:%|[a-zA-Z]+)?`, "", "number"),
+				rule(`"[^"]*"?|'[^']*'?`, "", "string"),
+				rule(`!important`, "", "keyword"),
+				rule(`[\w-]+`, "", ""),
+			},
+		},
+	}
+}
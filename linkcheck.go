@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+var linkRegex = regexp.MustCompile(`\s(href|src)\s*=\s*"([^"]*)"`)
+var anchorRegex = regexp.MustCompile(`\s(id|name)\s*=\s*"([^"]*)"`)
+
+// Scripts may contain strings that look like attributes, hence their bodies are not scanned for links.
+var scriptBodyRegex = regexp.MustCompile(`(?is)(<script[^>]*>).*?</script>`)
+
+// brokenLink describes a link in the generated output whose target does not exist.
+type brokenLink struct {
+	// Source markdown file of the page containing the link
+	fname string
+	// Line in the markdown file or 0 if the link has been emitted by a template
+	line   int
+	link   string
+	reason string
+}
+
+func (l *brokenLink) String() string {
+	if l.line > 0 {
+		return fmt.Sprintf("%v:%v: broken link %v: %v", l.fname, l.line, l.link, l.reason)
+	}
+	return fmt.Sprintf("%v: broken link %v: %v", l.fname, l.link, l.reason)
+}
+
+// linkChecker resolves the links of all generated HTML files against the output file system.
+type linkChecker struct {
+	b *Builder
+	// The ids (and anchor names) of all HTML files read so far, indexed by their slash-separated path.
+	anchors map[string]map[string]bool
+}
+
+// checkLinks reports all links in the generated HTML that point to missing files or anchors.
+// It must be called after `generate`.
+func (b *Builder) checkLinks() error {
+	c := &linkChecker{b: b, anchors: make(map[string]map[string]bool)}
+	var broken []*brokenLink
+	for _, gen := range b.generators {
+		page := gen.Page()
+		if page.Fname == "" {
+			continue
+		}
+		// Entities in the markdown tell on which line a link is used.
+		lines := make(map[string]int)
+		for _, e := range page.Document.Entities() {
+			link := e.Value
+			switch e.Name {
+			case "a", "img":
+			case "ref":
+				// The HTML holds the URL of the referenced page
+				var err error
+				if link, err = gen.ref(e.Value); err != nil {
+					continue
+				}
+			default:
+				continue
+			}
+			if lines[link] == 0 {
+				lines[link] = e.Line
+			}
+		}
+		for _, o := range page.Outputs {
+			if !o.Format.isHTML() {
+				continue
+			}
+			data, err := afero.ReadFile(b.outputFs, filepath.FromSlash(strings.TrimPrefix(o.RelURL, "/")))
+			if err != nil {
+				return err
+			}
+			for _, link := range htmlLinks(data) {
+				if reason := c.check(o.RelURL, link); reason != "" {
+					broken = append(broken, &brokenLink{fname: page.Fname, line: lines[link], link: link, reason: reason})
+				}
+			}
+		}
+	}
+	if len(broken) == 0 {
+		return nil
+	}
+	sort.Slice(broken, func(i, j int) bool {
+		if broken[i].fname != broken[j].fname {
+			return broken[i].fname < broken[j].fname
+		}
+		return broken[i].line < broken[j].line
+	})
+	for _, l := range broken {
+		fmt.Fprintln(os.Stderr, l.String())
+	}
+	return fmt.Errorf("Found %v broken links", len(broken))
+}
+
+// htmlLinks returns the values of all href and src attributes in `data`.
+func htmlLinks(data []byte) []string {
+	var links []string
+	data = scriptBodyRegex.ReplaceAll(data, []byte("$1</script>"))
+	for _, m := range linkRegex.FindAllSubmatch(data, -1) {
+		links = append(links, html.UnescapeString(string(m[2])))
+	}
+	return links
+}
+
+// resolveLink resolves `link`, which is used by the page at `pageURL`, to a slash-separated path in the output file system.
+// The path of root-relative links is stripped of the path of `baseURL`.
+// It returns false for links which are not checked, i.e. empty links and links to other hosts or with a scheme like "mailto:".
+func resolveLink(pageURL string, link string, baseURL *url.URL) (target string, u *url.URL, ok bool, err error) {
+	u, err = url.Parse(link)
+	if err != nil {
+		return "", nil, false, err
+	}
+	if u.Scheme != "" || u.Host != "" || link == "" {
+		return "", u, false, nil
+	}
+	target = pageURL
+	if strings.HasPrefix(u.Path, "/") {
+		target = u.Path
+		if baseURL != nil && baseURL.Path != "" && baseURL.Path != "/" {
+			target = "/" + strings.TrimPrefix(strings.TrimPrefix(target, baseURL.Path), "/")
+		}
+	} else if u.Path != "" {
+		target = path.Join(path.Dir(pageURL), u.Path)
+	}
+	return target, u, true, nil
+}
+
+// check resolves `link`, which is used by the page at `pageURL`.
+// It returns the empty string if the link target exists, otherwise the reason why the link is broken.
+func (c *linkChecker) check(pageURL string, link string) string {
+	target, u, ok, err := resolveLink(pageURL, link, c.b.options.BaseURL)
+	if err != nil {
+		return "malformed URL"
+	}
+	// External links, mailto: and the like are not checked.
+	if !ok {
+		return ""
+	}
+	fpath := filepath.FromSlash(strings.TrimPrefix(target, "/"))
+	s, err := c.b.outputFs.Stat(fpath)
+	if err == nil && s.IsDir() {
+		target = path.Join(target, "index.html")
+		fpath = filepath.Join(fpath, "index.html")
+		_, err = c.b.outputFs.Stat(fpath)
+	}
+	if err != nil {
+		return "no such file"
+	}
+	if u.Fragment == "" || !strings.HasSuffix(target, ".html") {
+		return ""
+	}
+	anchors, err := c.readAnchors(target, fpath)
+	if err != nil {
+		return err.Error()
+	}
+	if !anchors[u.Fragment] {
+		return "no such anchor"
+	}
+	return ""
+}
+
+func (c *linkChecker) readAnchors(target string, fpath string) (map[string]bool, error) {
+	if anchors, ok := c.anchors[target]; ok {
+		return anchors, nil
+	}
+	data, err := afero.ReadFile(c.b.outputFs, fpath)
+	if err != nil {
+		return nil, err
+	}
+	anchors := make(map[string]bool)
+	for _, m := range anchorRegex.FindAllStringSubmatch(string(data), -1) {
+		anchors[html.UnescapeString(m[2])] = true
+	}
+	c.anchors[target] = anchors
+	return anchors, nil
+}
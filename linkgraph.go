@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+// linkGraphPage is the JSON representation of a page in the link graph.
+type linkGraphPage struct {
+	URL   string `json:"url"`
+	Title string `json:"title"`
+	// Path of the markdown file relative to the content directory
+	Source string `json:"source"`
+	// Files included by the markdown file, as listed by `Page.Dependencies`
+	Dependencies []string `json:"dependencies,omitempty"`
+	Outbound     []string `json:"outbound"`
+	Inbound      []string `json:"inbound"`
+}
+
+// linkGraph is the JSON structure written to the link graph file.
+type linkGraph struct {
+	Pages []*linkGraphPage `json:"pages"`
+	// URLs of all pages (except for the homepage) to which no other page links
+	Orphans []string `json:"orphans"`
+}
+
+// Backlinks returns all pages which link to this page, sorted by their RelURL.
+func (ctx *PageContext) Backlinks() []*PageContext {
+	return ctx.backlinks
+}
+
+// OutboundLinks returns all pages to which this page links, sorted by their RelURL.
+func (ctx *PageContext) OutboundLinks() []*PageContext {
+	return ctx.outboundLinks
+}
+
+// Orphans returns all pages (except for the homepage) to which no other page links, sorted by their RelURL.
+func (s *SiteContext) Orphans() []*PageContext {
+	var list []*PageContext
+	for _, p := range s.Pages {
+		page := p.(*PageContext)
+		if len(page.backlinks) == 0 && (s.Folder == nil || s.Folder.Page != page) {
+			list = append(list, page)
+		}
+	}
+	sortPagesByURL(list)
+	return list
+}
+
+// usesLinks returns true if the templates of the page show links between pages.
+// These templates are executed again once all links are known.
+func (gen *HTMLGenerator) usesLinks() bool {
+	var texts []string
+	for _, o := range gen.outputs {
+		texts = append(append(texts, o.Base), o.Layouts...)
+	}
+	for _, p := range gen.partials {
+		texts = append(texts, p)
+	}
+	for _, t := range texts {
+		if strings.Contains(t, ".Backlinks") || strings.Contains(t, ".OutboundLinks") || strings.Contains(t, ".Orphans") {
+			return true
+		}
+	}
+	return false
+}
+
+// collectLinks determines the links between all pages of the site.
+// `links` holds the links of all generated HTML files. Links emitted by templates while rendering
+// `Backlinks`, `OutboundLinks` or `Orphans` are therefore not part of the link graph.
+func (b *Builder) collectLinks(links map[*PageOutput][]string) {
+	// Map all generated files to their page
+	byURL := make(map[string]*PageContext)
+	for _, p := range b.site.ctx.Pages {
+		page := p.(*PageContext)
+		for _, o := range page.page.Outputs {
+			byURL[o.RelURL] = page
+		}
+	}
+	// The links are assigned to the pages only after all pages have been processed.
+	// Otherwise the order in which pages are processed would influence the result.
+	outbound := make(map[*PageContext][]*PageContext)
+	backlinks := make(map[*PageContext][]*PageContext)
+	for _, p := range b.site.ctx.Pages {
+		page := p.(*PageContext)
+		for _, o := range page.page.Outputs {
+			for _, link := range links[o] {
+				target, _, ok, err := resolveLink(o.RelURL, link, b.options.BaseURL)
+				if err != nil || !ok {
+					continue
+				}
+				dest, ok := byURL[target]
+				if !ok {
+					dest, ok = byURL[strings.TrimSuffix(target, "/")+"/index.html"]
+				}
+				if !ok || dest == page || containsPage(outbound[page], dest) {
+					continue
+				}
+				outbound[page] = append(outbound[page], dest)
+				backlinks[dest] = append(backlinks[dest], page)
+			}
+		}
+	}
+	for _, p := range b.site.ctx.Pages {
+		page := p.(*PageContext)
+		page.outboundLinks = outbound[page]
+		page.backlinks = backlinks[page]
+		sortPagesByURL(page.outboundLinks)
+		sortPagesByURL(page.backlinks)
+	}
+}
+
+// generateLinkGraph writes a JSON file that lists all links between pages and all orphan pages.
+func (b *Builder) generateLinkGraph() error {
+	if b.linkGraph == "" {
+		return nil
+	}
+	graph := &linkGraph{Pages: []*linkGraphPage{}, Orphans: []string{}}
+	var pages []*PageContext
+	for _, p := range b.site.ctx.Pages {
+		pages = append(pages, p.(*PageContext))
+	}
+	sortPagesByURL(pages)
+	for _, page := range pages {
+		title, _ := page.Title()
+		gp := &linkGraphPage{URL: page.RelURL(), Title: title, Source: filepath.ToSlash(page.page.Fname), Dependencies: page.page.Dependencies, Outbound: []string{}, Inbound: []string{}}
+		for _, dest := range page.outboundLinks {
+			gp.Outbound = append(gp.Outbound, dest.RelURL())
+		}
+		for _, src := range page.backlinks {
+			gp.Inbound = append(gp.Inbound, src.RelURL())
+		}
+		graph.Pages = append(graph.Pages, gp)
+	}
+	for _, page := range b.site.ctx.Orphans() {
+		println("Orphan page", page.page.Fname)
+		graph.Orphans = append(graph.Orphans, page.RelURL())
+	}
+
+	data, err := json.MarshalIndent(graph, "", "  ")
+	if err != nil {
+		return err
+	}
+	outpath := filepath.FromSlash(b.linkGraph)
+	err = b.outputFs.MkdirAll(filepath.Dir(outpath), 0775)
+	if err != nil {
+		return err
+	}
+	println("Writing link graph", outpath, "...")
+	return afero.WriteFile(b.outputFs, outpath, data, 0660)
+}
+
+func containsPage(list []*PageContext, page *PageContext) bool {
+	for _, p := range list {
+		if p == page {
+			return true
+		}
+	}
+	return false
+}
+
+func sortPagesByURL(list []*PageContext) {
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].page.RelURL < list[j].page.RelURL
+	})
+}
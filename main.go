@@ -21,6 +21,8 @@ type options struct {
 	searchPath string
 	port       string
 	server     bool
+	// Report links in the generated HTML that point to missing files or anchors.
+	checkLinks bool
 }
 
 func main() {
@@ -30,6 +32,7 @@ func main() {
 	flag.StringVar(&options.outputPath, "out", "", "Destination directory for the generated HTML, scripts, CSS, and images")
 	flag.StringVar(&options.searchPath, "path", "", "Semi-colon separated list of directories that are searched for page types or bundles")
 	flag.StringVar(&baseURL, "url", "/", "The (relative) URL to be used for the generated content")
+	flag.BoolVar(&options.checkLinks, "check-links", false, "Report internal links in the generated HTML that point to missing files or anchors")
 	// flag.BoolVar(&options.server, "server", false, "Start the MaTeS server to be able to edit code on the fly")
 	// flag.StringVar(&options.port, "port", "8080", "The port on which MaTeS server should listen for connections")
 	flag.Parse()
@@ -0,0 +1,45 @@
+package main
+
+// parseMarkdownString parses `text` with the grammar of the generator's page.
+// Resources required by the tags, entities and styles used in `text` are added to the page.
+func (gen *HTMLGenerator) parseMarkdownString(name string, text string) (*DocumentNode, error) {
+	parser := NewParser(gen.page.Grammar, []byte(text), gen.resolver)
+	doc, err := parser.ParseMarkdown()
+	if err != nil {
+		return nil, funcError(name, "%v", err)
+	}
+	gen.addDocumentResources(doc)
+	return doc, nil
+}
+
+// markdownify turns `text`, e.g. a frontmatter value, into HTML as if it was part of the page's markdown.
+func (gen *HTMLGenerator) markdownify(text string) (string, error) {
+	doc, err := gen.parseMarkdownString("markdownify", text)
+	if err != nil {
+		return "", err
+	}
+	str, err := gen.innerHTML(doc)
+	if err != nil {
+		return "", funcError("markdownify", "%v", err)
+	}
+	return str, nil
+}
+
+// markdownifyInline works like `markdownify`, but a text consisting of a single paragraph
+// is returned without the surrounding paragraph tag.
+func (gen *HTMLGenerator) markdownifyInline(text string) (string, error) {
+	doc, err := gen.parseMarkdownString("markdownifyInline", text)
+	if err != nil {
+		return "", err
+	}
+	var str string
+	if len(doc.Children) == 1 && doc.Children[0].Tag == "#p" && len(doc.Children[0].Children) == 0 {
+		str, err = gen.innerText(doc.Children[0])
+	} else {
+		str, err = gen.innerHTML(doc)
+	}
+	if err != nil {
+		return "", funcError("markdownifyInline", "%v", err)
+	}
+	return str, nil
+}
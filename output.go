@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/weistn/template"
+)
+
+// OutputFormat describes a representation in which a page can be generated, e.g. HTML, JSON or plain text.
+type OutputFormat struct {
+	// Name of the format as used by "Outputs" in page.yaml, e.g. "json".
+	// The templates of the format are named accordingly, e.g. "base.json" and "layout.json".
+	Name string
+	// File extension of the generated files including the leading dot, e.g. ".json".
+	Extension string
+	// Media type of the generated files, e.g. "application/json".
+	// Templates can read it as `.OutputFormat.MediaType`, e.g. to link to alternative representations of a page.
+	// Only files of the media type "text/html" are searched for links between pages.
+	MediaType string
+}
+
+// isHTML returns true if the generated files are HTML documents.
+func (f *OutputFormat) isHTML() bool {
+	mediaType := strings.TrimSpace(strings.Split(f.MediaType, ";")[0])
+	return mediaType == "text/html" || mediaType == "application/xhtml+xml"
+}
+
+// PageOutput describes a file that is generated for a page in a certain output format.
+type PageOutput struct {
+	Format *OutputFormat
+	// Slash-separated path to the generated file, like "/recipes/beef/index.json".
+	RelURL string
+}
+
+// OutputTemplate holds the base and layout templates used to generate one output format of a page.
+type OutputTemplate struct {
+	Format  *OutputFormat
+	Base    string
+	Layouts []string
+	// The parsed templates. This field is populated by `HTMLGenerator.Prepare`.
+	template *template.Template
+}
+
+// newBuiltinOutputFormats returns the output formats which are known without any configuration.
+func newBuiltinOutputFormats() map[string]*OutputFormat {
+	return map[string]*OutputFormat{
+		"html": {Name: "html", Extension: ".html", MediaType: "text/html"},
+		"json": {Name: "json", Extension: ".json", MediaType: "application/json"},
+		"txt":  {Name: "txt", Extension: ".txt", MediaType: "text/plain"},
+		"xml":  {Name: "xml", Extension: ".xml", MediaType: "application/xml"},
+	}
+}
+
+// addOutputFormatsFromYaml processes the "OutputFormats" map of site.yaml.
+// Each entry defines a new output format or overrides the settings of a builtin one.
+func addOutputFormatsFromYaml(formats map[string]*OutputFormat, y interface{}, filename string) error {
+	m, err := yamlMap("OutputFormats", y, filename)
+	if err != nil {
+		return err
+	}
+	for name, v := range m {
+		props, err := yamlMap(name, v, filename)
+		if err != nil {
+			return fmt.Errorf("%v OutputFormats: %v", filename, err)
+		}
+		f := &OutputFormat{Name: name, Extension: "." + name, MediaType: "text/plain"}
+		if builtin, ok := formats[name]; ok {
+			*f = *builtin
+		}
+		for k, pv := range props {
+			switch k {
+			case "Extension":
+				f.Extension, err = yamlString(k, pv, filename)
+				if err == nil && !strings.HasPrefix(f.Extension, ".") {
+					f.Extension = "." + f.Extension
+				}
+			case "MediaType":
+				f.MediaType, err = yamlString(k, pv, filename)
+			default:
+				return fmt.Errorf("%v OutputFormats: %v: unknown attribute %v", filename, name, k)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		formats[name] = f
+	}
+	return nil
+}
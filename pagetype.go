@@ -37,6 +37,21 @@ type pageType struct {
 	// A list of resources required by the page, such as CSS, JS, images etc.
 	resources []*Resource
 	varDefs   map[string]*VarDef
+	// Names of the output formats to generate, as specified by "Outputs" in page.yaml. May be empty.
+	outputs []string
+	// Range of heading levels listed in the table of contents, as specified by "TOCLevels" in page.yaml. May be empty.
+	tocLevelSpec string
+	// Theme and output ("classes" or "inline") of syntax highlighting, as specified by "HighlightTheme" and "HighlightStyle" in page.yaml. May be empty.
+	highlightTheme string
+	highlightStyle string
+	// Output of formulas ("mathml" or "tex"), as specified by "Math" in page.yaml. May be empty.
+	math string
+	// Number of words of automatic summaries, as specified by "SummaryLength" in page.yaml. Zero if not specified.
+	summaryWords int
+	// Settings of the image pipeline, as specified by "Images" in page.yaml. May be nil.
+	images *imageConfig
+	// The HTML template of a builtin page type that is not the default page type. May be empty.
+	builtinHTML string
 }
 
 // Default HTML tempalte to use for a page in case nothing else has been specified.
@@ -56,6 +71,91 @@ var defaultBaseHTML = `<!doctype html>
 </html>
 `
 
+// HTML template of the builtin "search" page type.
+// It loads the search index generated by the builder and queries it in the browser.
+var searchBaseHTML = `<!doctype html>
+<html>
+	<!-- Search template -->
+	<head>
+	<meta charset="utf-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1, shrink-to-fit=no">
+	{{block "css" .}}{{.Styles}}{{end}}
+	{{block "js" .}}{{.Scripts}}{{end}}
+	<title>{{ block "title" . }}{{ .Site.Title }}{{ end }}</title>
+	</head>
+	<body>
+		{{block "main" .}}{{.Content}}{{end}}
+		<form class="search" onsubmit="return false;">
+			<input id="search-input" type="search" name="q" placeholder="Search" autocomplete="off">
+		</form>
+		<ul id="search-results" class="search-results" data-index="{{.Site.SearchIndex}}"></ul>
+		<script type="text/javascript">
+		(function () {
+			var input = document.getElementById("search-input");
+			var results = document.getElementById("search-results");
+			var index = null;
+			function tokenize(text) {
+				return text.toLowerCase().split(/[^\p{L}\p{N}]+/u).filter(function (t) { return t.length >= index.minTokenLength; });
+			}
+			// Escapes text for use in HTML content and attribute values
+			function escape(text) {
+				return String(text).replace(/&/g, "&amp;").replace(/</g, "&lt;").replace(/>/g, "&gt;").replace(/"/g, "&quot;").replace(/'/g, "&#39;");
+			}
+			function search() {
+				if (index === null) {
+					return;
+				}
+				var tokens = tokenize(input.value);
+				var scores = null;
+				tokens.forEach(function (token, i) {
+					var found = {};
+					// The last token is treated as a prefix, because the user might still be typing.
+					var keys = i === tokens.length - 1 ? Object.keys(index.index).filter(function (k) { return k.indexOf(token) === 0; }) : [token];
+					keys.forEach(function (k) {
+						(index.index[k] || []).forEach(function (posting) {
+							found[posting[0]] = (found[posting[0]] || 0) + posting[1];
+						});
+					});
+					if (scores === null) {
+						scores = found;
+					} else {
+						// All tokens must match
+						for (var p in scores) {
+							if (!(p in found)) {
+								delete scores[p];
+							} else {
+								scores[p] += found[p];
+							}
+						}
+					}
+				});
+				var pages = Object.keys(scores || {}).sort(function (a, b) { return scores[b] - scores[a]; });
+				var html = "";
+				pages.forEach(function (p) {
+					var page = index.pages[p];
+					html += '<li><a href="' + escape(page.url) + '">' + escape(page.title || page.url) + '</a>';
+					if (page.text) {
+						html += "<p>" + escape(page.text.substring(0, 200)) + "</p>";
+					}
+					html += "</li>";
+				});
+				results.innerHTML = html;
+			}
+			input.addEventListener("input", search);
+			var q = new URLSearchParams(window.location.search).get("q");
+			if (q) {
+				input.value = q;
+			}
+			fetch(results.getAttribute("data-index")).then(function (r) { return r.json(); }).then(function (data) {
+				index = data;
+				search();
+			});
+		})();
+		</script>
+	</body>
+</html>
+`
+
 func newNonePageType() *pageType {
 	return &pageType{name: "__none__"}
 }
@@ -76,6 +176,10 @@ func newDefaultCategoryValuePageType() *pageType {
 	return &pageType{name: "__default_catval__"}
 }
 
+func newSearchPageType() *pageType {
+	return &pageType{name: "search", builtinHTML: searchBaseHTML}
+}
+
 // Load "page.yaml" and lookup all resources.
 func newPageType(fs afero.Fs, path string, name string, bundle *bundle, b *Builder) (p *pageType, err error) {
 	p = &pageType{fs: fs, path: path, name: name, bundle: bundle}
@@ -142,6 +246,53 @@ func newPageType(fs afero.Fs, path string, name string, bundle *bundle, b *Build
 			if err != nil {
 				return nil, err
 			}
+		case "Outputs":
+			p.outputs, err = yamlStringOrStrings(k, v, configFilePath)
+			if err != nil {
+				return nil, err
+			}
+		case "TOCLevels":
+			p.tocLevelSpec, err = yamlString(k, v, configFilePath)
+			if err != nil {
+				return nil, err
+			}
+			if _, _, err = parseTOCLevels(p.tocLevelSpec); err != nil {
+				return nil, fmt.Errorf("In %v: %v", configFilePath, err)
+			}
+		case "HighlightTheme":
+			p.highlightTheme, err = yamlString(k, v, configFilePath)
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := highlightThemes[p.highlightTheme]; !ok {
+				return nil, fmt.Errorf("In %v: Unknown highlight theme %v", configFilePath, p.highlightTheme)
+			}
+		case "HighlightStyle":
+			p.highlightStyle, err = yamlString(k, v, configFilePath)
+			if err != nil {
+				return nil, err
+			}
+			if p.highlightStyle != "classes" && p.highlightStyle != "inline" {
+				return nil, fmt.Errorf("In %v: HighlightStyle must be either classes or inline", configFilePath)
+			}
+		case "Math":
+			p.math, err = yamlString(k, v, configFilePath)
+			if err != nil {
+				return nil, err
+			}
+			if p.math != "mathml" && p.math != "tex" {
+				return nil, fmt.Errorf("In %v: Math must be either mathml or tex", configFilePath)
+			}
+		case "SummaryLength":
+			p.summaryWords, err = parseSummaryLength(v, configFilePath)
+			if err != nil {
+				return nil, err
+			}
+		case "Images":
+			p.images = &imageConfig{}
+			if err = p.images.addFromYaml(v, configFilePath, false); err != nil {
+				return nil, err
+			}
 		default:
 			log.Printf("Unknown attribute %v in page %v", k, configFilePath)
 		}
@@ -154,7 +305,7 @@ func (p *pageType) isNone() bool {
 }
 
 func (p *pageType) isDefault() bool {
-	return p.name == "__default__" || p.name == "__default_folder__" || p.name == "__default_cat__" || p.name == "__default_catval__"
+	return p.name == "__default__" || p.name == "__default_folder__" || p.name == "__default_cat__" || p.name == "__default_catval__" || p.builtinHTML != ""
 }
 
 func (p *pageType) getMarkdownSyntax() ([]byte, string, error) {
@@ -170,15 +321,32 @@ func (p *pageType) getMarkdownSyntax() ([]byte, string, error) {
 	return data, path, nil
 }
 
-func (p *pageType) getBaseHTML() (string, string, error) {
+// outputFormatNames returns the names of all output formats that are generated for pages of this type.
+// A page type without "Outputs" inherits them from its parent page type or generates HTML only.
+func (p *pageType) outputFormatNames() []string {
+	for pt := p; pt != nil; pt = pt.inheritPageType {
+		if len(pt.outputs) > 0 {
+			return pt.outputs
+		}
+	}
+	return []string{"html"}
+}
+
+func (p *pageType) getBase(f *OutputFormat) (string, string, error) {
 	if p.isNone() {
 		return "", "", os.ErrNotExist
 	}
 	// if p.name == "__default__" || p.name == "__default_folder__" {
 	if p.isDefault() {
+		if !f.isHTML() {
+			return "", "", os.ErrNotExist
+		}
+		if p.builtinHTML != "" {
+			return p.builtinHTML, "__builtin__", nil
+		}
 		return defaultBaseHTML, "__builtin__", nil
 	}
-	path := filepath.Join(p.path, "base.html")
+	path := filepath.Join(p.path, "base."+f.Name)
 	data, err := afero.ReadFile(p.fs, path)
 	if err != nil {
 		return "", "", err
@@ -187,11 +355,11 @@ func (p *pageType) getBaseHTML() (string, string, error) {
 	return string(data), path, nil
 }
 
-func (p *pageType) getLayoutHTML() (string, string, error) {
+func (p *pageType) getLayout(f *OutputFormat) (string, string, error) {
 	if p.isNone() || p.isDefault() {
 		return "", "", nil
 	}
-	path := filepath.Join(p.path, "layout.html")
+	path := filepath.Join(p.path, "layout."+f.Name)
 	data, err := afero.ReadFile(p.fs, path)
 	if err != nil {
 		return "", "", err
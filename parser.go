@@ -1,12 +1,15 @@
 package main
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"html"
 	"log"
 	"net/url"
+	"path"
 	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/kylelemons/go-gypsy/yaml"
@@ -32,8 +35,23 @@ type Parser struct {
 	variables map[string]string
 	rootNode  *DocumentNode
 	resolver  ResourceResolver
+	// Path of the markdown file being parsed. Included files are searched relative to it.
+	fname string
+	// Loads the files referenced by #include. If nil, #include is not supported.
+	includeResolver IncludeResolver
+	// The files which include the file being parsed, used to detect cycles.
+	includeStack []string
+	// Paths of all files included directly or indirectly, including the data files of tables. Each path is listed once.
+	Includes []string
+	// Loads the files referenced by the src attribute of #code and tables. If nil, loading source files is not supported.
+	sourceResolver SourceResolver
+	// Numbers of the footnotes referenced so far, indexed by label or inline text
+	footnotes map[string]int
 }
 
+// IncludeResolver returns the markdown of the file at the slash-separated path `fname`.
+type IncludeResolver func(fname string) ([]byte, error)
+
 // NewDocument creates an empty document
 func NewDocument(grammar *Grammar) *DocumentNode {
 	return &DocumentNode{Tag: "Root", TagDefinition: grammar.GetTag("#root")}
@@ -43,7 +61,13 @@ func NewDocument(grammar *Grammar) *DocumentNode {
 func NewParser(grammar *Grammar, markdown []byte, resolver ResourceResolver) *Parser {
 	root := NewDocument(grammar)
 	s := NewScanner(markdown)
-	return &Parser{grammar: grammar, counters: make(map[string]int), variables: make(map[string]string), rootNode: root, s: s, resolver: resolver}
+	return &Parser{grammar: grammar, counters: make(map[string]int), variables: make(map[string]string), rootNode: root, s: s, resolver: resolver, footnotes: make(map[string]int)}
+}
+
+// EnableIncludes allows for using #include in the markdown file at the slash-separated path `fname`.
+func (parser *Parser) EnableIncludes(fname string, resolver IncludeResolver) {
+	parser.fname = fname
+	parser.includeResolver = resolver
 }
 
 // ParseFrontmatter strips YAML frontmatter from the markdown data and parses it.
@@ -69,6 +93,10 @@ func (parser *Parser) ParseMarkdown() (*DocumentNode, error) {
 	if err != nil {
 		return nil, err
 	}
+	layoutTables(doc, parser.fname)
+	if err := evaluateFormulas(doc, parser.fname); err != nil {
+		return nil, err
+	}
 	return doc, nil
 }
 
@@ -83,7 +111,27 @@ func (parser *Parser) newDocumentNode(tag string, indent int) *DocumentNode {
 		log.Printf("Unknown tag type '%v'", tag)
 		tagdef = parser.grammar.GetTag("#p")
 	}
-	return &DocumentNode{Tag: tag, TagDefinition: tagdef, Document: parser.rootNode, Indent: indent}
+	return &DocumentNode{Tag: tag, TagDefinition: tagdef, Document: parser.rootNode, Indent: indent, Line: parser.tokenLine()}
+}
+
+// tokenLine returns the line (counting from 1) of the current token.
+// The range of a token can include text preceding it, which might span several lines.
+func (parser *Parser) tokenLine() int {
+	from := parser.tokenPos.From
+	to := parser.tokenPos.To
+	if to > len(parser.s.src) {
+		to = len(parser.s.src)
+	}
+	if from > to {
+		from = to
+	}
+	text := parser.s.src[from:to]
+	if i := bytes.LastIndex(text, []byte(parser.tokenStr)); i > 0 && parser.tokenStr != "" {
+		text = text[:i]
+	} else {
+		text = text[:len(text)-len(bytes.TrimLeft(text, " \t\r\n"))]
+	}
+	return parser.tokenPos.FromLine + bytes.Count(text, []byte("\n")) + 1
 }
 
 func (parser *Parser) parseConfig() (map[string]interface{}, error) {
@@ -461,6 +509,7 @@ func (parser *Parser) parse() (doc *DocumentNode, err error) {
 				var secmode = sectionNormal
 				var resources []*Resource
 				var firstChild bool
+				var heading int
 				parentHood := NoParentHood
 				// Expect YAML config text
 				parser.s.mode = modeConfig
@@ -538,6 +587,15 @@ func (parser *Parser) parse() (doc *DocumentNode, err error) {
 						if err == nil && str == "true" {
 							firstChild = true
 						}
+					case "Heading":
+						var str string
+						str, err = yamlString(k, v)
+						if err == nil {
+							heading, err = strconv.Atoi(str)
+							if err != nil || heading < 1 || heading > 4 {
+								return nil, fmt.Errorf("%v: Invalid heading level '%v' in template section '%v'", parser.tokenPos.FromLine, str, section)
+							}
+						}
 					case "Parenthood":
 						var bs string
 						bs, err = yamlString(k, v)
@@ -617,7 +675,7 @@ func (parser *Parser) parse() (doc *DocumentNode, err error) {
 					return nil, err
 				}
 				resources = append(resources, htmlResources...)
-				tagDef := &TagDefinition{Name: section, DefaultParent: defaultParent, HTMLTemplate: text2, Counter: counter, ResetCounter: resetCounters, PossibleParents: parents, ClassShortcuts: shortcss, DefaultAttribute: defattr, SectionMode: secmode, Resources: resources, ParentHood: parentHood, FirstChild: firstChild}
+				tagDef := &TagDefinition{Name: section, DefaultParent: defaultParent, HTMLTemplate: text2, Counter: counter, ResetCounter: resetCounters, PossibleParents: parents, ClassShortcuts: shortcss, DefaultAttribute: defattr, SectionMode: secmode, Resources: resources, ParentHood: parentHood, FirstChild: firstChild, Heading: heading}
 				err = parser.grammar.addCustomTag(tagDef)
 				if err != nil {
 					return nil, err
@@ -820,6 +878,56 @@ func (parser *Parser) parse() (doc *DocumentNode, err error) {
 				if tag.SectionMode == sectionTable {
 					tableInbody = false
 				}
+				// Tags containing a table, not its rows and cells
+				if tag.SectionMode == sectionTable && tag.DefaultParent == nil {
+					if err := parseTableLayout(node); err != nil {
+						return nil, fmt.Errorf("%v:%v: %v", parser.fname, node.Line, err)
+					}
+					parser.s.formulas = node.table.formulas
+				}
+				// Replace the #include node with the nodes of the included file
+				if tag.Name == "#include" {
+					included, err := parser.include(node.Attributes[tag.DefaultAttribute])
+					if err != nil {
+						return nil, fmt.Errorf("%v:%v: %v", parser.fname, node.Line, err)
+					}
+					closeTags(len(tagStack) - 1)
+					p := node.Parent
+					p.RemoveChild(node)
+					if node.PrevSibling != nil {
+						node.PrevSibling.NextSibling = nil
+					}
+					for _, c := range included.Children {
+						c.PrevSibling = nil
+						appendChild(p, c)
+					}
+				}
+				// Load the code from a source file
+				if tag.Name == "#code" && node.Attributes["src"] != "" {
+					if err := parser.loadCode(node); err != nil {
+						return nil, fmt.Errorf("%v:%v: %v", parser.fname, node.Line, err)
+					}
+					// The text following the tag is not part of the code block.
+					// It has been scanned as code already while parsing the attributes.
+					closeTags(len(tagStack) - 1)
+					if parser.token == TokenCodeText {
+						parser.s.rewind(parser.tokenPos)
+						parser.next()
+					}
+				}
+				// Load the rows of a table from a data file
+				if tag.SectionMode == sectionTable && node.Attributes["src"] != "" {
+					if err := parser.loadTable(node); err != nil {
+						return nil, fmt.Errorf("%v:%v: %v", parser.fname, node.Line, err)
+					}
+					// Rows following the tag start a new table
+					closeTags(len(tagStack) - 1)
+				}
+				if tag.SectionMode == sectionCode {
+					if err := parseCodeAnnotations(node); err != nil {
+						return nil, fmt.Errorf("%v:%v: %v", parser.fname, node.Line, err)
+					}
+				}
 			}
 		case TokenEnum:
 			indent := parser.s.indent
@@ -937,7 +1045,7 @@ func (parser *Parser) parse() (doc *DocumentNode, err error) {
 			}
 			if secmode != sectionMedia { // Ignore text in a media context
 				p := parent()
-				text := &MathNode{Text: parser.tokenStr, Parent: p}
+				text := &MathNode{Text: parser.tokenStr, Parent: p, Line: parser.tokenLine()}
 				p.AppendText(text)
 			}
 			parser.next()
@@ -1016,6 +1124,7 @@ func (parser *Parser) parse() (doc *DocumentNode, err error) {
 				openTableCell()
 			}
 			name := parser.tokenStr
+			entityPos := parser.tokenPos
 			var value string
 			if pos := strings.Index(name, ":"); pos != -1 {
 				value = name[pos+1:]
@@ -1027,12 +1136,22 @@ func (parser *Parser) parse() (doc *DocumentNode, err error) {
 				log.Printf("%v:%v: Unknown entity: '%v'", parser.s.lineCount, parser.s.lineOffset, name)
 			} else {
 				p := parent()
-				node := &EntityNode{Parent: p, Attributes: attribs, Name: name, EntityDefinition: edef, Value: value}
-				incCounter(edef.Counter)
+				node := &EntityNode{Parent: p, Attributes: attribs, Name: name, EntityDefinition: edef, Value: value, Line: entityPos.FromLine + 1}
+				// A footnote referenced more than once keeps the number of its first reference
+				number, isRepeatedFootnote := parser.footnotes[value]
+				isRepeatedFootnote = isRepeatedFootnote && name == "fn"
+				if !isRepeatedFootnote {
+					incCounter(edef.Counter)
+				}
 				node.Counters = make(map[string]int)
 				for k, v := range parser.counters {
 					node.Counters[k] = v
 				}
+				if isRepeatedFootnote {
+					node.Counters["fn"] = number
+				} else if name == "fn" {
+					parser.footnotes[value] = node.Counters["fn"]
+				}
 				p.AppendText(node)
 			}
 		case TokenConfigText:
@@ -1101,6 +1220,62 @@ func (parser *Parser) parse() (doc *DocumentNode, err error) {
 	return parser.rootNode, nil
 }
 
+// include parses the file at `fname`, which is relative to the file being parsed unless it starts with a slash.
+// The included file shares the counters of the including file.
+func (parser *Parser) include(fname string) (*DocumentNode, error) {
+	if parser.includeResolver == nil {
+		return nil, errors.New("#include is not supported here")
+	}
+	if fname == "" {
+		return nil, errors.New("#include requires a file name")
+	}
+	if !strings.HasPrefix(fname, "/") {
+		fname = path.Join(path.Dir(parser.fname), fname)
+	}
+	fname = strings.TrimPrefix(path.Clean("/"+fname), "/")
+	for _, f := range append(parser.includeStack, parser.fname) {
+		if f == fname {
+			return nil, fmt.Errorf("Cyclic #include of %v", fname)
+		}
+	}
+	markdown, err := parser.includeResolver(fname)
+	if err != nil {
+		return nil, fmt.Errorf("#include: %v", err)
+	}
+	child := NewParser(parser.grammar, markdown, parser.resolver)
+	child.counters = parser.counters
+	child.footnotes = parser.footnotes
+	child.EnableIncludes(fname, parser.includeResolver)
+	child.EnableSourceFiles(parser.sourceResolver)
+	child.includeStack = append(append([]string{}, parser.includeStack...), parser.fname)
+	// Frontmatter of included files is ignored
+	if _, err = child.ParseFrontmatter(); err != nil {
+		return nil, fmt.Errorf("In %v: YAML parsing error: %v", fname, err)
+	}
+	doc, err := child.ParseMarkdown()
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range doc.DocumentNodes() {
+		n.Document = parser.rootNode
+	}
+	parser.addInclude(fname)
+	for _, f := range child.Includes {
+		parser.addInclude(f)
+	}
+	return doc, nil
+}
+
+// addInclude adds `fname` to `parser.Includes` unless it is already listed.
+func (parser *Parser) addInclude(fname string) {
+	for _, f := range parser.Includes {
+		if f == fname {
+			return
+		}
+	}
+	parser.Includes = append(parser.Includes, fname)
+}
+
 func removeBOM(data []byte) []byte {
 	if len(data) >= 3 && data[0] == 0xef && data[1] == 0xbb && data[2] == 0xbf {
 		return data[3:]
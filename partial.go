@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+// readPartials loads all files in the directory `dir` of `fs` as partial templates and adds them to `partials`.
+// The name of a partial is the slash-separated path of its file relative to `dir` without the file extension,
+// e.g. "partials/cards/post.html" is named "cards/post".
+// A missing directory is not an error.
+func readPartials(fs afero.Fs, dir string, partials map[string]string) error {
+	if _, err := fs.Stat(dir); os.IsNotExist(err) {
+		return nil
+	}
+	return afero.Walk(fs, dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		data, err := afero.ReadFile(fs, path)
+		if err != nil {
+			return err
+		}
+		name := strings.TrimSuffix(filepath.ToSlash(rel), filepath.Ext(rel))
+		partials[name] = string(data)
+		return nil
+	})
+}
+
+// lookupPartials returns all partial templates available to pages of the page type `pt`.
+// Partials are loaded from the bundle, then from the site, and finally from the page type and its parent page types.
+// Partials loaded later override partials of the same name loaded earlier, i.e. the most specific page type wins.
+// Resources referenced by the partials are resolved in the static directory next to the partials
+// and, if not found there, like those of base and layout templates.
+func (b *Builder) lookupPartials(pt *pageType) (map[string]string, []*Resource, error) {
+	partials := make(map[string]string)
+	var res []*Resource
+	load := func(fs afero.Fs, dir string, resolver ResourceResolver) error {
+		found := make(map[string]string)
+		if err := readPartials(fs, filepath.Join(dir, "partials"), found); err != nil {
+			return err
+		}
+		for name, text := range found {
+			text, partialRes, err := ParseHTMLResources(text, resolver)
+			if err != nil {
+				return fmt.Errorf("partial %v: %v", name, err)
+			}
+			partials[name] = text
+			res = append(res, partialRes...)
+		}
+		return nil
+	}
+	ptResolver := func(r *Resource) error {
+		return pt.resolveStaticResource(r, pt)
+	}
+	if b.bundle != nil {
+		dest := filepath.Join(string(filepath.Separator), "_static", "bundles", b.bundle.name)
+		if err := load(b.bundle.bundleFs, "", staticResolver(b.bundle.bundleFs, dest, ptResolver)); err != nil {
+			return nil, nil, err
+		}
+	}
+	dest := filepath.Join(string(filepath.Separator), "_static", "site")
+	if err := load(b.site.siteFs, "", staticResolver(b.site.siteFs, dest, ptResolver)); err != nil {
+		return nil, nil, err
+	}
+	// Page types are processed from the root of the inheritance chain downwards
+	var chain []*pageType
+	for p := pt; p != nil; p = p.inheritPageType {
+		if p.fs != nil {
+			chain = append([]*pageType{p}, chain...)
+		}
+	}
+	for _, p := range chain {
+		if err := load(p.fs, p.path, ptResolver); err != nil {
+			return nil, nil, err
+		}
+	}
+	return partials, res, nil
+}
+
+// staticResolver returns a resolver that searches resources in the directory "static" of `fs`.
+// Resolved resources are copied to `dest`. Resources which are not found there are resolved by `fallback`.
+func staticResolver(fs afero.Fs, dest string, fallback ResourceResolver) ResourceResolver {
+	return func(res *Resource) error {
+		if res.Resolved || res.URL.IsAbs() {
+			return nil
+		}
+		// The resource URL path is relative to "/". If not, force it.
+		resPath := filepath.Clean(filepath.Join(string(filepath.Separator), filepath.FromSlash(res.URL.Path)))[1:]
+		searchFile := filepath.Join("static", resPath)
+		_, err := fs.Stat(searchFile)
+		if os.IsNotExist(err) {
+			return fallback(res)
+		} else if err != nil {
+			return err
+		}
+		res.SourcePath = searchFile
+		res.SourceFs = fs
+		res.DestPath = filepath.Join(dest, searchFile)
+		res.URL.Path = filepath.ToSlash(res.DestPath)
+		res.Resolved = true
+		return nil
+	}
+}
+
+// executePartial executes the partial template `name` with `data` as context.
+// While generating an output format, the partial is taken from the templates of that format.
+func (gen *HTMLGenerator) executePartial(name string, data interface{}) (string, error) {
+	t := gen.textTemplate
+	if gen.output != nil {
+		t = gen.output.template
+	}
+	if t.Lookup("partials/"+name) == nil {
+		return "", fmt.Errorf("Unknown partial %v", name)
+	}
+	w := bytes.NewBuffer(nil)
+	err := t.ExecuteTemplate(w, "partials/"+name, data)
+	if err != nil {
+		return "", err
+	}
+	return w.String(), nil
+}
+
+// cachedPartial is the result of a partial executed by `partialCached`.
+type cachedPartial struct {
+	str string
+	// Resources added to the page while executing the partial
+	resources []*Resource
+}
+
+// executePartialCached works like `executePartial`, but the result is computed only once per site and output format.
+// The `variants` are part of the cache key. They allow for caching different results of the same partial.
+func (gen *HTMLGenerator) executePartialCached(name string, data interface{}, variants []interface{}) (string, error) {
+	s, ok := gen.pageContext.siteContext.(*SiteContext)
+	if !ok {
+		return gen.executePartial(name, data)
+	}
+	key := name
+	if gen.output != nil {
+		key = gen.output.Format.Name + ":" + key
+	}
+	for _, v := range variants {
+		key += "\x00" + fmt.Sprint(v)
+	}
+	if c, ok := s.site.partialCache[key]; ok {
+		// The cached output still requires the resources added when it was computed
+		for _, r := range c.resources {
+			gen.addResource(r, true)
+		}
+		return c.str, nil
+	}
+	// Record the resources requested by the partial, including those the page already has
+	start := len(gen.recorded)
+	gen.recording++
+	str, err := gen.executePartial(name, data)
+	gen.recording--
+	resources := append([]*Resource(nil), gen.recorded[start:]...)
+	if gen.recording == 0 {
+		gen.recorded = nil
+	}
+	if err != nil {
+		return "", err
+	}
+	s.site.partialCache[key] = &cachedPartial{str: str, resources: resources}
+	return str, nil
+}
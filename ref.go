@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// lookupPage returns the page generated from the markdown file at `ref` or nil.
+// `ref` is a slash-separated path relative to the content directory, like "recipes/beef.md".
+// The ".md" suffix can be omitted and a folder like "recipes" denotes its "index.md".
+func (s *site) lookupPage(ref string) *PageContext {
+	ref = strings.TrimPrefix(path.Clean("/"+ref), "/")
+	if ref == "" {
+		ref = "index.md"
+	}
+	for _, p := range []string{ref, ref + ".md", path.Join(ref, "index.md")} {
+		if page, ok := s.pages[p]; ok {
+			return page
+		}
+	}
+	return nil
+}
+
+// GetPage returns the page generated from the markdown file at `ref` or nil if there is no such page.
+// `ref` is relative to the content directory, like "recipes/beef.md".
+func (s *SiteContext) GetPage(ref string) *PageContext {
+	return s.site.lookupPage(ref)
+}
+
+// resolveRef finds the page referenced by `ref`, which is of the form "path/to/page.md#anchor".
+// A path starting with a slash is relative to the content directory, all other paths are relative to the folder
+// of the generator's page. A relative path which denotes another page when read from the content directory
+// is ambiguous and logs a warning.
+// The function returns the page and the anchor, which is empty if `ref` has none.
+func (gen *HTMLGenerator) resolveRef(ref string) (*PageContext, string, error) {
+	s, ok := gen.pageContext.siteContext.(*SiteContext)
+	if !ok {
+		return nil, "", fmt.Errorf("Cannot resolve reference %v without a site", ref)
+	}
+	refPath := ref
+	anchor := ""
+	if i := strings.Index(ref, "#"); i >= 0 {
+		refPath = ref[:i]
+		anchor = ref[i+1:]
+	}
+	// A reference to an anchor inside the same page
+	if refPath == "" {
+		return gen.pageContext, anchor, nil
+	}
+	if strings.HasPrefix(refPath, "/") {
+		page := s.site.lookupPage(refPath)
+		if page == nil {
+			return nil, "", fmt.Errorf("Reference to unknown page %v", ref)
+		}
+		return gen.checkRef(page, ref, anchor)
+	}
+	page := s.site.lookupPage(path.Join(path.Dir(filepath.ToSlash(gen.page.Fname)), refPath))
+	absolute := s.site.lookupPage(refPath)
+	if page == nil {
+		if absolute != nil {
+			return nil, "", fmt.Errorf("Reference to unknown page %v. Use /%v for the page relative to the content directory", ref, refPath)
+		}
+		return nil, "", fmt.Errorf("Reference to unknown page %v", ref)
+	}
+	if absolute != nil && absolute != page && !gen.warnedRefs[ref] {
+		log.Printf("%v: Reference %v is ambiguous. It denotes %v and not /%v", gen.page.Fname, ref, page.page.Fname, filepath.ToSlash(absolute.page.Fname))
+		gen.warnedRefs[ref] = true
+	}
+	return gen.checkRef(page, ref, anchor)
+}
+
+// checkRef returns the page found by `resolveRef` unless it is not generated.
+func (gen *HTMLGenerator) checkRef(page *PageContext, ref string, anchor string) (*PageContext, string, error) {
+	if page.page.RelURL == "" {
+		return nil, "", fmt.Errorf("Reference to page %v which is not generated", ref)
+	}
+	return page, anchor, nil
+}
+
+// ref returns the URL of the page referenced by `ref` including the base URL, like "/docs/page.html#anchor".
+func (gen *HTMLGenerator) ref(ref string) (string, error) {
+	page, anchor, err := gen.resolveRef(ref)
+	if err != nil {
+		return "", err
+	}
+	u := page.page.RelURL
+	if gen.options != nil && gen.options.BaseURL != nil {
+		u = strings.TrimSuffix(gen.options.BaseURL.String(), "/") + u
+	}
+	if anchor != "" {
+		u += "#" + anchor
+	}
+	return u, nil
+}
+
+// relref returns the URL of the page referenced by `ref` relative to the generator's page, like "../docs/page.html#anchor".
+func (gen *HTMLGenerator) relref(ref string) (string, error) {
+	page, anchor, err := gen.resolveRef(ref)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(filepath.FromSlash(path.Dir(gen.page.RelURL)), filepath.FromSlash(page.page.RelURL))
+	if err != nil {
+		return "", err
+	}
+	u := filepath.ToSlash(rel)
+	if anchor != "" {
+		u += "#" + anchor
+	}
+	return u, nil
+}
+
+// refText returns the text of a link created by ~ref~. If the reference has an anchor, this is the text of the
+// node with that id. Otherwise it is the title of the page as specified in its config or, if there is none,
+// the text of its first heading.
+func refText(page *PageContext, anchor string) (string, error) {
+	doc := page.page.Document
+	if anchor != "" {
+		doc.ids()
+		for _, n := range doc.DocumentNodes() {
+			if n.forcedID == anchor || n.Attributes["label"] == anchor {
+				if text := strings.TrimSpace(n.PlainText()); text != "" {
+					return text, nil
+				}
+			}
+		}
+	}
+	if page.page.DerivedTitle {
+		for _, n := range doc.DocumentNodes() {
+			if n.TagDefinition != nil && n.TagDefinition.Heading > 0 {
+				if text := strings.TrimSpace(n.PlainText()); text != "" {
+					return text, nil
+				}
+			}
+		}
+	}
+	return page.Title()
+}
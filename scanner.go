@@ -98,6 +98,10 @@ type Scanner struct {
 	lineOffset int  // start of the current line in `src`.
 	lineCount  int
 	indent     int // The indentation level of the last tag
+	// The current table cell starts with '=' and holds a formula
+	formula bool
+	// True if the cells of the current table can hold formulas
+	formulas bool
 
 	// Errors denotes the lexicographical errors detected while scanning.
 	Errors []ScannerError
@@ -145,6 +149,18 @@ func (scanner *Scanner) next() {
 	}
 }
 
+// rewind moves the scanner back to the beginning of the token at `r`, which is scanned again in normal mode.
+// This is used when the parser learns only after looking ahead that a token must be scanned differently.
+func (scanner *Scanner) rewind(r ScannerRange) {
+	scanner.readOffset = r.From
+	scanner.lineOffset = r.From - r.FromLinePos
+	scanner.lineCount = r.FromLine
+	scanner.ch = 0
+	scanner.mode = modeNormal
+	scanner.textMode = textNormal
+	scanner.next()
+}
+
 func (scanner *Scanner) skipWhitespace(newline bool) {
 	for scanner.ch == ' ' || scanner.ch == '\t' || (newline && scanner.ch == '\n') || scanner.ch == '\r' {
 		scanner.next()
@@ -275,10 +291,19 @@ func (scanner *Scanner) scan() (Token, string) {
 		return TokenSection, ""
 	}
 	// Beginning of file? -> Move to the first non-whitespace character
-	if scanner.offset == 0 {
+	if scanner.offset == 0 && scanner.readOffset == 0 {
 		scanner.next()
 		scanner.skipWhitespace(true)
 	}
+	// Formulas are not scanned for markup, since they use characters like '*'
+	if scanner.formula {
+		scanner.formula = false
+		start := scanner.offset
+		for scanner.ch != -1 && scanner.ch != '|' && scanner.ch != '\n' {
+			scanner.next()
+		}
+		return TokenText, string(scanner.src[start:scanner.offset])
+	}
 scanAgain:
 	switch scanner.ch {
 	case -1: // EOF
@@ -361,6 +386,10 @@ scanAgain:
 	case '|':
 		// Table mode or start of a new table?
 		if scanner.mode == modeNormal && (scanner.textMode == textTable || scanner.isStartOfLine()) {
+			// Tables without a tag have no formulas
+			if scanner.textMode != textTable {
+				scanner.formulas = false
+			}
 			scanner.textMode = textTable
 			start := scanner.offset
 			// Skip all following `|` characters (required for multi-column cells)
@@ -380,6 +409,7 @@ scanAgain:
 				}
 				return TokenTableRow, string(scanner.src[start:end])
 			}
+			scanner.formula = scanner.formulas && scanner.ch == '='
 			return TokenTableCell, string(scanner.src[start:end])
 		}
 	case '\r':
@@ -552,6 +582,10 @@ scanAgain:
 			scanner.next()
 			scanner.skipWhitespace(false)
 			newline = true
+			// The first row of a table follows the line break after a tag without attributes
+			if scanner.textMode == textTable && scanner.ch == '|' {
+				goto scanAgain
+			}
 			if scanner.ch == '\n' {
 				scanner.skipWhitespace(true)
 				// If a '#' or '-' or '>' follows the empty line then ignore the white space that has been skipped
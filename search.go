@@ -0,0 +1,214 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/spf13/afero"
+)
+
+// searchConfig holds the settings of the "Search" map in site.yaml.
+type searchConfig struct {
+	// Path of the generated index file in the output file system.
+	// An empty path disables the search index, which is the default unless site.yaml has a "Search" map.
+	index string
+	// Fields which are stored and indexed for each page.
+	// "url" is always stored, because search results must link somewhere.
+	fields []string
+	// Pages using one of these page types are not indexed.
+	excludeTypes []string
+	// Pages whose source path matches one of these patterns are not indexed.
+	excludePaths []string
+	// Tokens shorter than this are not indexed.
+	minTokenLength int
+}
+
+// searchIndex is the JSON structure written to the index file.
+type searchIndex struct {
+	Fields         []string `json:"fields"`
+	MinTokenLength int      `json:"minTokenLength"`
+	// One entry per indexed page. Only the configured fields are populated.
+	Pages []*searchPage `json:"pages"`
+	// Maps a token to the pages containing it.
+	// Each posting is a pair of page number (an index into `Pages`) and score.
+	Index map[string][][2]int `json:"index"`
+}
+
+type searchPage struct {
+	Title string              `json:"title,omitempty"`
+	URL   string              `json:"url"`
+	Type  string              `json:"type,omitempty"`
+	Tags  map[string][]string `json:"tags,omitempty"`
+	Text  string              `json:"text,omitempty"`
+}
+
+// Scores of a token depending on the field in which it has been found.
+var searchFieldScores = map[string]int{"title": 10, "tags": 5, "type": 1, "text": 1}
+
+// defaultSearchIndex is the path of the search index unless "Index" in site.yaml specifies otherwise.
+const defaultSearchIndex = "search.json"
+
+func newSearchConfig() *searchConfig {
+	return &searchConfig{fields: []string{"title", "url", "type", "tags", "text"}, excludeTypes: []string{"search"}, minTokenLength: 2}
+}
+
+// addFromYaml processes the "Search" map of site.yaml, which enables the search index.
+func (c *searchConfig) addFromYaml(y interface{}, filename string) error {
+	m, err := yamlMap("Search", y, filename)
+	if err != nil {
+		return err
+	}
+	c.index = defaultSearchIndex
+	for k, v := range m {
+		switch k {
+		case "Index":
+			c.index, err = yamlString(k, v, filename)
+		case "Fields":
+			c.fields, err = yamlStringOrStrings(k, v, filename)
+			if err == nil {
+				for _, f := range c.fields {
+					if _, ok := searchFieldScores[f]; !ok && f != "url" {
+						return fmt.Errorf("%v Search: unknown field %v", filename, f)
+					}
+				}
+			}
+		case "ExcludeTypes":
+			c.excludeTypes, err = yamlStringOrStrings(k, v, filename)
+		case "Exclude":
+			c.excludePaths, err = yamlStringOrStrings(k, v, filename)
+		case "MinTokenLength":
+			var str string
+			str, err = yamlString(k, v, filename)
+			if err == nil {
+				c.minTokenLength, err = strconv.Atoi(str)
+			}
+		default:
+			return fmt.Errorf("%v Search: unknown attribute %v", filename, k)
+		}
+		if err != nil {
+			return fmt.Errorf("%v Search: %v", filename, err)
+		}
+	}
+	return nil
+}
+
+func (c *searchConfig) hasField(field string) bool {
+	for _, f := range c.fields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// excludes returns true if the page must not be part of the search index.
+func (c *searchConfig) excludes(page *Page) bool {
+	for _, t := range c.excludeTypes {
+		if t == page.PageTypeName {
+			return true
+		}
+	}
+	for _, pattern := range c.excludePaths {
+		if ok, _ := filepath.Match(pattern, filepath.ToSlash(page.Fname)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// generateSearchIndex writes a JSON file that allows for searching the site without a server.
+func (b *Builder) generateSearchIndex() error {
+	if b.search.index == "" {
+		return nil
+	}
+	index := &searchIndex{Fields: b.search.fields, MinTokenLength: b.search.minTokenLength, Index: make(map[string][][2]int)}
+	// Iterate over the pages in a stable order, such that the index does not change between builds.
+	var paths []string
+	for path := range b.generators {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		page := b.generators[path].Page()
+		if page.Fname == "" || b.search.excludes(page) {
+			continue
+		}
+		title, _ := b.generators[path].PageContext().Title()
+		sp := &searchPage{URL: page.RelURL}
+		scores := make(map[string]int)
+		addTokens := func(field string, text string) {
+			for _, t := range tokenize(text, b.search.minTokenLength) {
+				scores[t] += searchFieldScores[field]
+			}
+		}
+		for _, f := range b.search.fields {
+			switch f {
+			case "title":
+				sp.Title = title
+				addTokens(f, title)
+			case "type":
+				sp.Type = page.PageTypeName
+				addTokens(f, page.PageTypeName)
+			case "tags":
+				sp.Tags = page.Tags
+				for _, values := range page.Tags {
+					addTokens(f, strings.Join(values, " "))
+				}
+			case "text":
+				sp.Text = documentPlainText(page.Document)
+				addTokens(f, sp.Text)
+			}
+		}
+		pageNumber := len(index.Pages)
+		index.Pages = append(index.Pages, sp)
+		for t, score := range scores {
+			index.Index[t] = append(index.Index[t], [2]int{pageNumber, score})
+		}
+	}
+
+	data, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+	outpath := filepath.FromSlash(b.search.index)
+	err = b.outputFs.MkdirAll(filepath.Dir(outpath), 0775)
+	if err != nil {
+		return err
+	}
+	println("Writing search index", outpath, "...")
+	return afero.WriteFile(b.outputFs, outpath, data, 0660)
+}
+
+// documentPlainText returns the text of all DocumentNodes in the tree, separated by spaces.
+func documentPlainText(node *DocumentNode) string {
+	var parts []string
+	if text := strings.TrimSpace(node.PlainText()); text != "" {
+		parts = append(parts, text)
+	}
+	for _, c := range node.Children {
+		if text := documentPlainText(c); text != "" {
+			parts = append(parts, text)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// tokenize splits text into lower case words.
+// The search page type uses the same rules when tokenizing the query.
+func tokenize(text string, minLength int) []string {
+	var result []string
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	for _, w := range words {
+		if len([]rune(w)) >= minLength {
+			result = append(result, w)
+		}
+	}
+	return result
+}
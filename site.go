@@ -22,6 +22,10 @@ type site struct {
 	config map[string]interface{}
 	ctx    *SiteContext
 	tags   *Tags
+	// All pages of the site indexed by the slash-separated path of their markdown file, like "recipes/beef.md".
+	pages map[string]*PageContext
+	// Results of `partialCached` indexed by output format, partial name and variants
+	partialCache map[string]*cachedPartial
 }
 
 // SiteContext is passed to page templates as .Site context.
@@ -33,7 +37,9 @@ type SiteContext struct {
 	Pages []interface{}
 	// Folder of the homepage.
 	Folder *FolderContext
-	site   *site
+	// Slash-separated path of the search index, like "/search.json", or empty if no index is generated.
+	SearchIndex string
+	site        *site
 }
 
 // FolderContext is passed to page templates as .Folder context.
@@ -115,7 +121,7 @@ func lookupSite(fs afero.Fs, path string) (*site, error) {
 					ctx := &SiteContext{Params: make(map[string]interface{})}
 					tags := newTags()
 					// TODO: In untrusted mode, the output must be within the site file system
-					site := &site{siteFs: siteFs, ctx: ctx, tags: tags, path: path, name: filepath.Base(orig), config: config, outputPath: outputPath, contentPath: contentPath}
+					site := &site{siteFs: siteFs, ctx: ctx, tags: tags, pages: make(map[string]*PageContext), partialCache: make(map[string]*cachedPartial), path: path, name: filepath.Base(orig), config: config, outputPath: outputPath, contentPath: contentPath}
 					ctx.site = site
 					return site, nil
 				}
@@ -131,7 +137,7 @@ func lookupSite(fs afero.Fs, path string) (*site, error) {
 			// Create the site context
 			ctx := &SiteContext{Params: make(map[string]interface{})}
 			tags := newTags()
-			site := &site{siteFs: siteFs, ctx: ctx, tags: tags, path: dir, name: filepath.Base(orig), config: make(map[string]interface{}), outputPath: "public", contentPath: "content"}
+			site := &site{siteFs: siteFs, ctx: ctx, tags: tags, pages: make(map[string]*PageContext), partialCache: make(map[string]*cachedPartial), path: dir, name: filepath.Base(orig), config: make(map[string]interface{}), outputPath: "public", contentPath: "content"}
 			ctx.site = site
 			return site, nil
 		}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// defaultSummaryLength is the number of words after which an automatic summary ends,
+// unless "SummaryLength" in page.yaml or site.yaml specifies otherwise.
+const defaultSummaryLength = 70
+
+// wordsPerMinute is the reading speed assumed by `ReadingTime`.
+const wordsPerMinute = 200
+
+// Summary returns a teaser of the page in HTML.
+// The `Summary` attribute of the page config takes precedence and is rendered as markdown.
+// Otherwise the summary consists of all top-level nodes of the document preceding the #more tag.
+// Without #more, the summary consists of the first `SummaryLength` words of the page.
+func (ctx *PageContext) Summary() (string, error) {
+	if v, ok := ctx.page.Params["Summary"]; ok {
+		str, err := yamlString("Summary", v, ctx.page.Fname)
+		if err != nil {
+			return "", err
+		}
+		html, err := ctx.gen.markdownify(str)
+		return stripSummaryHTML(html), err
+	}
+	doc := ctx.page.Document
+	more := doc.DocumentNodes("#more")
+	var top *DocumentNode
+	if len(more) != 0 {
+		top = more[0]
+		for top.Parent != doc {
+			top = top.Parent
+		}
+	}
+	html := ""
+	words := ctx.page.SummaryLength
+	for _, c := range doc.Children {
+		if c == top || (top == nil && words <= 0) {
+			break
+		}
+		str, err := ctx.gen.outerHTML(c)
+		if err != nil {
+			return "", err
+		}
+		if top == nil {
+			var n int
+			str, n = truncateHTMLWords(str, words)
+			words -= n
+		}
+		html += str
+	}
+	return stripSummaryHTML(html), nil
+}
+
+var summaryTagRegex = regexp.MustCompile(`<[^>]*>`)
+var summaryIDRegex = regexp.MustCompile(`\sid\s*=\s*"[^"]*"`)
+
+// stripSummaryHTML removes all id attributes, heading anchors and footnote references from the HTML of a summary.
+// Summaries are shown on other pages, e.g. lists of posts, where these ids would clash
+// and the footnotes do not exist. The ids inside of svg elements are kept, because the graphics refer to them.
+func stripSummaryHTML(html string) string {
+	result := ""
+	pos := 0
+	svg := 0
+	// The closing tag of an element that is removed together with its content
+	skipTo := ""
+	for _, m := range summaryTagRegex.FindAllStringIndex(html, -1) {
+		tag := html[m[0]:m[1]]
+		if skipTo != "" {
+			if tag == skipTo {
+				skipTo = ""
+				pos = m[1]
+			}
+			continue
+		}
+		result += html[pos:m[0]]
+		pos = m[1]
+		switch {
+		case strings.HasPrefix(tag, "<svg"):
+			svg++
+		case tag == "</svg>":
+			svg--
+		case svg > 0:
+		case strings.HasPrefix(tag, `<sup class="footnote-ref">`):
+			skipTo = "</sup>"
+			continue
+		case strings.HasPrefix(tag, `<a class="anchor" `):
+			skipTo = "</a>"
+			continue
+		default:
+			tag = summaryIDRegex.ReplaceAllString(tag, "")
+		}
+		result += tag
+	}
+	if skipTo == "" {
+		result += html[pos:]
+	}
+	return result
+}
+
+// truncateHTMLWords shortens HTML to its first `max` words and closes all elements left open.
+// It returns the HTML and the number of words it contains.
+// Text inside of script, style and svg elements is not counted and never truncated.
+func truncateHTMLWords(html string, max int) (string, int) {
+	var open []string
+	skip := 0
+	words := 0
+	inWord := false
+	for i := 0; i < len(html); i++ {
+		if html[i] == '<' {
+			end := strings.IndexByte(html[i:], '>')
+			if end == -1 {
+				break
+			}
+			tag := html[i+1 : i+end]
+			i += end
+			inWord = false
+			if strings.HasPrefix(tag, "!") || strings.HasPrefix(tag, "?") || strings.HasSuffix(tag, "/") {
+				continue
+			}
+			closing := strings.HasPrefix(tag, "/")
+			fields := strings.FieldsFunc(strings.TrimPrefix(tag, "/"), func(r rune) bool {
+				return unicode.IsSpace(r) || r == '/'
+			})
+			if len(fields) == 0 {
+				continue
+			}
+			name := strings.ToLower(fields[0])
+			switch name {
+			case "area", "base", "br", "col", "embed", "hr", "img", "input", "link", "meta", "source", "track", "wbr":
+				continue
+			}
+			if closing {
+				for k := len(open) - 1; k >= 0; k-- {
+					if open[k] == name {
+						open = open[:k]
+						break
+					}
+				}
+			} else {
+				open = append(open, name)
+			}
+			switch name {
+			case "script", "style", "svg":
+				if closing {
+					skip--
+				} else {
+					skip++
+				}
+			}
+			continue
+		}
+		if skip > 0 {
+			continue
+		}
+		space := html[i] == ' ' || html[i] == '\n' || html[i] == '\t' || html[i] == '\r'
+		if !space && !inWord {
+			if words == max {
+				result := strings.TrimRight(html[:i], " \n\t\r") + "…"
+				for k := len(open) - 1; k >= 0; k-- {
+					result += "</" + open[k] + ">"
+				}
+				return result, words
+			}
+			words++
+		}
+		inWord = !space
+	}
+	return html, words
+}
+
+// parseSummaryLength parses the attribute "SummaryLength" of page.yaml or site.yaml.
+func parseSummaryLength(v interface{}, filename string) (int, error) {
+	str, err := yamlString("SummaryLength", v, filename)
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.Atoi(str)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("In %v: SummaryLength must be a positive number", filename)
+	}
+	return n, nil
+}
+
+// summaryLength returns the number of words of automatic summaries as specified by "SummaryLength" in page.yaml.
+// A page type inherits the setting from its parent page type and finally from site.yaml.
+func (p *pageType) summaryLength(siteDefault int) int {
+	for pt := p; pt != nil; pt = pt.inheritPageType {
+		if pt.summaryWords != 0 {
+			return pt.summaryWords
+		}
+	}
+	return siteDefault
+}
+
+// Plain returns the text of the page without any markup.
+func (ctx *PageContext) Plain() string {
+	return documentPlainText(ctx.page.Document)
+}
+
+// WordCount returns the number of words in the text of the page.
+func (ctx *PageContext) WordCount() int {
+	return len(strings.Fields(ctx.Plain()))
+}
+
+// ReadingTime returns the estimated number of minutes required to read the page, rounded up.
+func (ctx *PageContext) ReadingTime() int {
+	return (ctx.WordCount() + wordsPerMinute - 1) / wordsPerMinute
+}
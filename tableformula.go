@@ -0,0 +1,450 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// tableGrid maps the positions of a table to its cells for evaluating formulas.
+//
+//	#table;footers:1;formulas
+//	|Item|Price|Count|Total|
+//	|Apples|0.5|12|=B2*C2|
+//	|Pears|0.8|5|=B3*C3|
+//	|Sum|||=SUM(D)|
+//
+// In tables with the attribute `formulas`, a cell starting with "=" holds a formula. Cells are referenced by column letter and row number, counting all rows
+// of the table from 1. A column without row number denotes the numbers of that column in the body of the table.
+// Formulas use + - * /, parentheses, ranges like "B2:C9" and the functions SUM, AVG, MIN, MAX and COUNT.
+type tableGrid struct {
+	cells [][]*DocumentNode
+	// Rows of the #tbody
+	bodyFrom, bodyTo int
+	formulas         map[*DocumentNode]string
+	// Cells holding formulas in the order of the markdown and the names of all cells, e.g. "B2"
+	order  []*DocumentNode
+	names  map[*DocumentNode]string
+	values map[*DocumentNode]float64
+	// Formulas being evaluated, to detect circular references
+	pending map[*DocumentNode]bool
+}
+
+// evaluateFormulas replaces the formulas in the cells of all tables of the document with their results.
+// Only tables with the attribute `formulas` are evaluated.
+func evaluateFormulas(doc *DocumentNode, fname string) error {
+	for _, node := range doc.DocumentNodes() {
+		if node.TagDefinition.SectionMode != sectionTable || node.TagDefinition.DefaultParent != nil {
+			continue
+		}
+		if node.table == nil || !node.table.formulas {
+			continue
+		}
+		grid := newTableGrid(node)
+		for _, cell := range grid.order {
+			v, err := grid.value(cell)
+			if err != nil {
+				return fmt.Errorf("%v:%v: Cell %v: %v", fname, cell.Line, grid.names[cell], err)
+			}
+			cell.Text = []Node{&TextNode{Text: formatFormulaResult(v), Parent: cell}}
+		}
+	}
+	return nil
+}
+
+// newTableGrid places the cells of the header, body and footer rows on a grid, taking colspans and rowspans into account.
+func newTableGrid(table *DocumentNode) *tableGrid {
+	grid := &tableGrid{formulas: make(map[*DocumentNode]string), names: make(map[*DocumentNode]string), values: make(map[*DocumentNode]float64), pending: make(map[*DocumentNode]bool)}
+	// The logical row index. Rows added for rowspans do not advance it.
+	r := 0
+	for _, tag := range []string{"#thead", "#tbody", "#tfoot"} {
+		for _, section := range table.Children {
+			if section.Tag != tag {
+				continue
+			}
+			if tag == "#tbody" {
+				grid.bodyFrom = r
+			}
+			// Like in HTML, a rowspan does not extend beyond the end of its section
+			end := r + len(section.Children)
+			for _, row := range section.Children {
+				for len(grid.cells) <= r {
+					grid.cells = append(grid.cells, nil)
+				}
+				col := 0
+				for _, cell := range row.Children {
+					for col < len(grid.cells[r]) && grid.cells[r][col] != nil {
+						col++
+					}
+					rowspan, colspan := cell.Rowspan, cell.Colspan
+					if rowspan < 1 {
+						rowspan = 1
+					}
+					if colspan < 1 {
+						colspan = 1
+					}
+					for i := r; i < r+rowspan && i < end; i++ {
+						for len(grid.cells) <= i {
+							grid.cells = append(grid.cells, nil)
+						}
+						for len(grid.cells[i]) < col+colspan {
+							grid.cells[i] = append(grid.cells[i], nil)
+						}
+						for k := col; k < col+colspan; k++ {
+							grid.cells[i][k] = cell
+						}
+					}
+					grid.names[cell] = cellName(r, col)
+					col += colspan
+					if f, ok := cellFormula(cell); ok {
+						grid.formulas[cell] = f
+						grid.order = append(grid.order, cell)
+					}
+				}
+				r++
+			}
+			if tag == "#tbody" {
+				grid.bodyTo = r
+			}
+		}
+	}
+	return grid
+}
+
+// cellFormula returns the formula of a cell, without the leading "=".
+func cellFormula(cell *DocumentNode) (string, bool) {
+	if len(cell.Children) > 0 || len(cell.Text) != 1 {
+		return "", false
+	}
+	t, ok := cell.Text[0].(*TextNode)
+	if !ok {
+		return "", false
+	}
+	s := strings.TrimSpace(t.Text)
+	if !strings.HasPrefix(s, "=") {
+		return "", false
+	}
+	return s[1:], true
+}
+
+// cell returns the cell at the given position or nil.
+func (grid *tableGrid) cell(r, c int) *DocumentNode {
+	if r < 0 || r >= len(grid.cells) || c < 0 || c >= len(grid.cells[r]) {
+		return nil
+	}
+	return grid.cells[r][c]
+}
+
+// value returns the number in a cell or the result of its formula.
+func (grid *tableGrid) value(cell *DocumentNode) (float64, error) {
+	if v, ok := grid.values[cell]; ok {
+		return v, nil
+	}
+	f, ok := grid.formulas[cell]
+	if !ok {
+		return strconv.ParseFloat(strings.TrimSpace(cell.PlainText()), 64)
+	}
+	if grid.pending[cell] {
+		return 0, errors.New("Circular reference")
+	}
+	grid.pending[cell] = true
+	p := &formulaParser{grid: grid, self: cell, src: f}
+	v, err := p.parse()
+	delete(grid.pending, cell)
+	if err != nil {
+		return 0, err
+	}
+	grid.values[cell] = v
+	return v, nil
+}
+
+// formulaParser evaluates a formula while parsing it.
+type formulaParser struct {
+	grid *tableGrid
+	// The cell holding the formula
+	self *DocumentNode
+	src  string
+	pos  int
+}
+
+func (p *formulaParser) parse() (float64, error) {
+	v, err := p.expr()
+	if err != nil {
+		return 0, err
+	}
+	if p.skipSpace(); p.pos < len(p.src) {
+		return 0, fmt.Errorf("Unexpected '%v' in formula '=%v'", p.src[p.pos:], p.src)
+	}
+	return v, nil
+}
+
+func (p *formulaParser) skipSpace() {
+	for p.pos < len(p.src) && (p.src[p.pos] == ' ' || p.src[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+// accept skips the character `ch` if it comes next.
+func (p *formulaParser) accept(ch byte) bool {
+	p.skipSpace()
+	if p.pos < len(p.src) && p.src[p.pos] == ch {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// expr parses a sum or difference.
+func (p *formulaParser) expr() (float64, error) {
+	v, err := p.term()
+	for err == nil {
+		var w float64
+		if p.accept('+') {
+			w, err = p.term()
+			v += w
+		} else if p.accept('-') {
+			w, err = p.term()
+			v -= w
+		} else {
+			break
+		}
+	}
+	return v, err
+}
+
+// term parses a product or quotient.
+func (p *formulaParser) term() (float64, error) {
+	v, err := p.factor()
+	for err == nil {
+		var w float64
+		if p.accept('*') {
+			w, err = p.factor()
+			v *= w
+		} else if p.accept('/') {
+			if w, err = p.factor(); err == nil && w == 0 {
+				return 0, errors.New("Division by zero")
+			}
+			v /= w
+		} else {
+			break
+		}
+	}
+	return v, err
+}
+
+// factor parses a number, a cell reference, a function call or an expression in parentheses.
+func (p *formulaParser) factor() (float64, error) {
+	if p.accept('-') {
+		v, err := p.factor()
+		return -v, err
+	}
+	if p.accept('(') {
+		v, err := p.expr()
+		if err == nil && !p.accept(')') {
+			err = fmt.Errorf("Missing ')' in formula '=%v'", p.src)
+		}
+		return v, err
+	}
+	p.skipSpace()
+	start := p.pos
+	if p.pos < len(p.src) && (isDigit(p.src[p.pos]) || p.src[p.pos] == '.') {
+		for p.pos < len(p.src) && (isDigit(p.src[p.pos]) || p.src[p.pos] == '.') {
+			p.pos++
+		}
+		v, err := strconv.ParseFloat(p.src[start:p.pos], 64)
+		if err != nil {
+			return 0, fmt.Errorf("Malformed number '%v' in formula '=%v'", p.src[start:p.pos], p.src)
+		}
+		return v, nil
+	}
+	name, row := p.name()
+	if name == "" {
+		if p.pos == len(p.src) {
+			return 0, fmt.Errorf("Unexpected end of formula '=%v'", p.src)
+		}
+		return 0, fmt.Errorf("Unexpected '%v' in formula '=%v'", p.src[p.pos:], p.src)
+	}
+	if row == 0 {
+		if p.accept('(') {
+			return p.call(strings.ToUpper(name))
+		}
+		return 0, fmt.Errorf("Unknown cell '%v'. Cells are referenced like 'B2'", name)
+	}
+	c := columnIndex(name)
+	cell := p.grid.cell(row-1, c)
+	if cell == nil {
+		return 0, fmt.Errorf("Unknown cell '%v'", cellName(row-1, c))
+	}
+	if strings.TrimSpace(cell.PlainText()) == "" {
+		return 0, nil
+	}
+	v, err := p.grid.value(cell)
+	if _, ok := err.(*strconv.NumError); ok {
+		return 0, fmt.Errorf("Cell %v is not a number: '%v'", cellName(row-1, c), strings.TrimSpace(cell.PlainText()))
+	}
+	return v, err
+}
+
+// name parses a function name, a column or a cell reference. The row is 0 if the name has no row number.
+func (p *formulaParser) name() (string, int) {
+	start := p.pos
+	for p.pos < len(p.src) && isLetter(p.src[p.pos]) {
+		p.pos++
+	}
+	name := p.src[start:p.pos]
+	digits := p.pos
+	for p.pos < len(p.src) && isDigit(p.src[p.pos]) {
+		p.pos++
+	}
+	if name == "" || digits == p.pos {
+		return name, 0
+	}
+	row, _ := strconv.Atoi(p.src[digits:p.pos])
+	if row == 0 {
+		p.pos = digits
+	}
+	return strings.ToUpper(name), row
+}
+
+// call evaluates a function whose arguments follow.
+func (p *formulaParser) call(fn string) (float64, error) {
+	switch fn {
+	case "SUM", "AVG", "AVERAGE", "MIN", "MAX", "COUNT":
+	default:
+		return 0, fmt.Errorf("Unknown function %v. Expected SUM, AVG, MIN, MAX or COUNT", fn)
+	}
+	var values []float64
+	if !p.accept(')') {
+		for {
+			v, err := p.arg()
+			if err != nil {
+				return 0, err
+			}
+			values = append(values, v...)
+			if p.accept(')') {
+				break
+			}
+			if !p.accept(',') && !p.accept(';') {
+				return 0, fmt.Errorf("Missing ')' in formula '=%v'", p.src)
+			}
+		}
+	}
+	switch fn {
+	case "COUNT":
+		return float64(len(values)), nil
+	case "SUM":
+		sum := 0.0
+		for _, v := range values {
+			sum += v
+		}
+		return sum, nil
+	}
+	if len(values) == 0 {
+		return 0, fmt.Errorf("%v of no numbers", fn)
+	}
+	result := values[0]
+	for _, v := range values[1:] {
+		switch fn {
+		case "AVG", "AVERAGE":
+			result += v
+		case "MIN":
+			result = math.Min(result, v)
+		case "MAX":
+			result = math.Max(result, v)
+		}
+	}
+	if fn == "AVG" || fn == "AVERAGE" {
+		result /= float64(len(values))
+	}
+	return result, nil
+}
+
+// arg evaluates an argument of a function, which is a column, a range or an expression.
+// Cells in columns and ranges which do not hold numbers are ignored.
+func (p *formulaParser) arg() ([]float64, error) {
+	p.skipSpace()
+	start := p.pos
+	name, row := p.name()
+	if name != "" && row == 0 && !p.accept('(') {
+		c := columnIndex(name)
+		return p.numbers(p.grid.bodyFrom, c, p.grid.bodyTo-1, c)
+	}
+	if name != "" && row != 0 && p.accept(':') {
+		p.skipSpace()
+		name2, row2 := p.name()
+		if name2 == "" || row2 == 0 {
+			return nil, fmt.Errorf("Malformed range in formula '=%v'. Expected a range like 'B2:B9'", p.src)
+		}
+		return p.numbers(row-1, columnIndex(name), row2-1, columnIndex(name2))
+	}
+	p.pos = start
+	v, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	return []float64{v}, nil
+}
+
+// numbers returns the numbers in a rectangle of cells. Each cell counts once, even if it spans several positions.
+func (p *formulaParser) numbers(r1, c1, r2, c2 int) ([]float64, error) {
+	if r1 > r2 {
+		r1, r2 = r2, r1
+	}
+	if c1 > c2 {
+		c1, c2 = c2, c1
+	}
+	var result []float64
+	seen := make(map[*DocumentNode]bool)
+	for r := r1; r <= r2; r++ {
+		for c := c1; c <= c2; c++ {
+			cell := p.grid.cell(r, c)
+			if cell == nil || cell == p.self || seen[cell] {
+				continue
+			}
+			seen[cell] = true
+			v, err := p.grid.value(cell)
+			if _, ok := err.(*strconv.NumError); ok {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, v)
+		}
+	}
+	return result, nil
+}
+
+// columnIndex returns the index of a column given by letters, i.e. 0 for "A" and 26 for "AA".
+func columnIndex(letters string) int {
+	c := 0
+	for _, ch := range strings.ToUpper(letters) {
+		c = c*26 + int(ch-'A') + 1
+	}
+	return c - 1
+}
+
+// cellName returns the name of the cell at the given position, e.g. "B2" for row 1 and column 1.
+func cellName(r, c int) string {
+	letters := ""
+	for c++; c > 0; c = (c - 1) / 26 {
+		letters = string(rune('A'+(c-1)%26)) + letters
+	}
+	return letters + strconv.Itoa(r+1)
+}
+
+// formatFormulaResult formats the result of a formula without the rounding errors of floating point arithmetic.
+func formatFormulaResult(v float64) string {
+	f, _ := strconv.ParseFloat(strconv.FormatFloat(v, 'g', 12, 64), 64)
+	return formatFloat(f)
+}
+
+func isLetter(ch byte) bool {
+	return (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z')
+}
+
+func isDigit(ch byte) bool {
+	return ch >= '0' && ch <= '9'
+}
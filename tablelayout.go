@@ -0,0 +1,348 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// tableLayout holds the layout attributes of a #table node or of another tag whose content is a table.
+//
+//	#table;caption:Quarterly\ sales;headers:2;rowheaders
+//	|Region|Sales||
+//	|^^|:Q1:|:Q2:|
+//	|North|10|12|
+//	|^^|11|13|
+//
+// `caption` is shown as the caption of the table.
+// `headers` is the number of header rows, 1 by default. With `headers:0` the table has no header.
+// `footers` is the number of rows at the end of the table which form its footer, e.g. for totals.
+// `rowheaders` turns the cells of the first column into row headers, `rowheaders:2` the cells of the first two columns.
+// `formulas` evaluates cells starting with "=" as formulas, see `tableGrid`.
+// A cell holding "^^" merges with the cell above. In tables of the #table tag, a colon at the start and/or the end
+// of a header cell aligns the column to the left, to the right or centers it, e.g. ":Name", "Price:" or ":Count:".
+// Other tables, e.g. the data of a #chart or tables without a tag, keep the colons as part of the header.
+type tableLayout struct {
+	caption    string
+	headers    int
+	footers    int
+	rowHeaders int
+	// True if colons in header cells specify the alignment of columns
+	alignMarkers bool
+	// True if cells starting with "=" hold formulas
+	formulas bool
+	// True once the cells have been laid out by `layoutTables`
+	done bool
+}
+
+// parseTableLayout removes the layout attributes from a table node and stores them in the node.
+func parseTableLayout(node *DocumentNode) error {
+	t := &tableLayout{headers: 1, alignMarkers: node.Tag == "#table"}
+	if v, ok := node.Attributes["caption"]; ok {
+		t.caption = unescapeEntityValue(v)
+	}
+	if v, ok := node.Attributes["headers"]; ok {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return fmt.Errorf("Malformed number of header rows '%v' in attribute headers", v)
+		}
+		t.headers = n
+	}
+	if v, ok := node.Attributes["footers"]; ok {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return fmt.Errorf("Malformed number of footer rows '%v' in attribute footers", v)
+		}
+		t.footers = n
+	}
+	if v, ok := node.Attributes["rowheaders"]; ok {
+		t.rowHeaders = 1
+		if v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return fmt.Errorf("Malformed number of columns '%v' in attribute rowheaders", v)
+			}
+			t.rowHeaders = n
+		}
+	}
+	_, t.formulas = node.Attributes["formulas"]
+	for _, k := range []string{"caption", "headers", "footers", "rowheaders", "formulas"} {
+		delete(node.Attributes, k)
+	}
+	node.table = t
+	return nil
+}
+
+// layoutTables moves header rows, merges cells marked with "^^" and aligns the columns of all tables in the document.
+// `fname` is the markdown file of the document, used in warnings.
+func layoutTables(doc *DocumentNode, fname string) {
+	for _, node := range doc.DocumentNodes() {
+		if node.TagDefinition.SectionMode != sectionTable || node.TagDefinition.DefaultParent != nil {
+			continue
+		}
+		// Tables without a tag keep the rows as parsed
+		if node.table == nil {
+			node.table = &tableLayout{headers: -1}
+		}
+		if node.table.done {
+			continue
+		}
+		node.table.done = true
+		if node.table.headers >= 0 {
+			node.moveHeaderRows(node.table.headers)
+		}
+		node.moveFooterRows(node.table.footers)
+		var align []string
+		for _, section := range node.Children {
+			switch section.Tag {
+			case "#thead":
+				align = layoutTableSection(fname, section, align, 0, node.table.alignMarkers, true)
+			case "#tbody", "#tfoot":
+				layoutTableSection(fname, section, align, node.table.rowHeaders, false, false)
+			}
+		}
+	}
+}
+
+// moveHeaderRows ensures that a table has `n` header rows by moving rows between #thead and #tbody.
+// The parser places the first row of a table in the header.
+func (node *DocumentNode) moveHeaderRows(n int) {
+	var thead, tbody *DocumentNode
+	for _, c := range node.Children {
+		switch c.Tag {
+		case "#thead":
+			thead = c
+		case "#tbody":
+			tbody = c
+		}
+	}
+	for thead != nil && len(thead.Children) > n {
+		row := thead.Children[len(thead.Children)-1]
+		if tbody == nil {
+			tbody = node.newTableNode("#tbody", thead)
+			node.insertChild(len(node.Children), tbody)
+		}
+		thead.removeChildAt(len(thead.Children) - 1)
+		tbody.insertChild(0, row)
+		row.retag("#tbody-row", "#tbody-cell")
+	}
+	for tbody != nil && len(tbody.Children) > 0 && (thead == nil || len(thead.Children) < n) {
+		row := tbody.Children[0]
+		if thead == nil {
+			thead = node.newTableNode("#thead", tbody)
+			node.insertChild(0, thead)
+		}
+		tbody.removeChildAt(0)
+		thead.insertChild(len(thead.Children), row)
+		row.retag("#thead-row", "#thead-cell")
+	}
+	for _, c := range []*DocumentNode{thead, tbody} {
+		if c != nil && len(c.Children) == 0 && c.Parent == node {
+			node.RemoveChild(c)
+			node.relinkChildren()
+		}
+	}
+}
+
+// moveFooterRows moves the last `n` rows of the #tbody into a #tfoot.
+func (node *DocumentNode) moveFooterRows(n int) {
+	var tbody *DocumentNode
+	for _, c := range node.Children {
+		if c.Tag == "#tbody" {
+			tbody = c
+		}
+	}
+	if n == 0 || tbody == nil {
+		return
+	}
+	tfoot := node.newTableNode("#tfoot", tbody)
+	node.insertChild(len(node.Children), tfoot)
+	for i := 0; i < n && len(tbody.Children) > 0; i++ {
+		row := tbody.Children[len(tbody.Children)-1]
+		tbody.removeChildAt(len(tbody.Children) - 1)
+		tfoot.insertChild(0, row)
+		row.retag("#tfoot-row", "#tfoot-cell")
+	}
+	if len(tbody.Children) == 0 {
+		node.RemoveChild(tbody)
+		node.relinkChildren()
+	}
+}
+
+// newTableNode creates a #thead, #tbody or #tfoot node with the same position in the markdown as `sibling`.
+func (node *DocumentNode) newTableNode(tag string, sibling *DocumentNode) *DocumentNode {
+	return &DocumentNode{Tag: tag, TagDefinition: sibling.TagDefinition.grammar.GetTag(tag), Document: node.Document, Indent: sibling.Indent, Line: sibling.Line}
+}
+
+// insertChild inserts a child node at the given position.
+func (node *DocumentNode) insertChild(pos int, child *DocumentNode) {
+	node.Children = append(node.Children, nil)
+	copy(node.Children[pos+1:], node.Children[pos:])
+	node.Children[pos] = child
+	child.Parent = node
+	node.relinkChildren()
+}
+
+// removeChildAt removes the child node at the given position.
+func (node *DocumentNode) removeChildAt(pos int) {
+	node.RemoveChild(node.Children[pos])
+	node.relinkChildren()
+}
+
+// relinkChildren updates the sibling links of the child nodes.
+func (node *DocumentNode) relinkChildren() {
+	var prev *DocumentNode
+	for _, c := range node.Children {
+		c.PrevSibling = prev
+		c.NextSibling = nil
+		if prev != nil {
+			prev.NextSibling = c
+		}
+		prev = c
+	}
+}
+
+// retag turns a row and its cells into header or body nodes.
+func (row *DocumentNode) retag(rowTag, cellTag string) {
+	g := row.TagDefinition.grammar
+	row.Tag, row.TagDefinition = rowTag, g.GetTag(rowTag)
+	for _, cell := range row.Children {
+		cell.Tag, cell.TagDefinition = cellTag, g.GetTag(cellTag)
+	}
+}
+
+// layoutTableSection merges the cells of a #thead, #tbody or #tfoot node which are marked with "^^" into the cell above.
+// In the header, it reads the alignment markers if `alignMarkers` is true and returns the alignment of each column.
+// In the body and the footer, it aligns the cells as given by `align` and marks the cells of the first `rowHeaders` columns as row headers.
+func layoutTableSection(fname string, section *DocumentNode, align []string, rowHeaders int, alignMarkers bool, header bool) []string {
+	// The cells covering each column of the previous row
+	var above []*DocumentNode
+	for _, row := range section.Children {
+		var covered []*DocumentNode
+		for i := 0; i < len(row.Children); i++ {
+			cell := row.Children[i]
+			col := len(covered)
+			if isRowspanMarker(cell) {
+				if col < len(above) {
+					// The merged cell covers the same columns as in the row above
+					merged := above[col]
+					merged.Rowspan++
+					for k := col; k < len(above) && above[k] == merged; k++ {
+						covered = append(covered, merged)
+					}
+					row.removeChildAt(i)
+					i--
+					continue
+				}
+				log.Printf("%v:%v: A table cell '^^' has no cell above to merge with", fname, cell.Line)
+			}
+			colspan := cell.Colspan
+			if colspan < 1 {
+				colspan = 1
+			}
+			if cell.Rowspan < 1 {
+				cell.Rowspan = 1
+			}
+			switch {
+			case header && alignMarkers:
+				if a := parseAlignMarker(cell); a != "" {
+					for len(align) < col+colspan {
+						align = append(align, "")
+					}
+					for k := col; k < col+colspan; k++ {
+						align[k] = a
+					}
+					cell.align = a
+				}
+			case !header:
+				if col < len(align) {
+					cell.align = align[col]
+				}
+				cell.rowHeader = col < rowHeaders
+			}
+			for k := 0; k < colspan; k++ {
+				covered = append(covered, cell)
+			}
+		}
+		above = covered
+	}
+	return align
+}
+
+// isRowspanMarker returns true if a table cell holds nothing but "^^".
+func isRowspanMarker(cell *DocumentNode) bool {
+	if len(cell.Children) > 0 {
+		return false
+	}
+	for _, t := range cell.Text {
+		if _, ok := t.(*TextNode); !ok {
+			return false
+		}
+	}
+	return strings.TrimSpace(cell.PlainText()) == "^^"
+}
+
+// parseAlignMarker removes the alignment colons from a header cell and returns the alignment.
+func parseAlignMarker(cell *DocumentNode) string {
+	if len(cell.Text) == 0 {
+		return ""
+	}
+	first, ok1 := cell.Text[0].(*TextNode)
+	last, ok2 := cell.Text[len(cell.Text)-1].(*TextNode)
+	if !ok1 || !ok2 || strings.TrimSpace(cell.PlainText()) == ":" {
+		return ""
+	}
+	left := strings.HasPrefix(strings.TrimSpace(first.Text), ":")
+	if left {
+		first.Text = strings.Replace(first.Text, ":", "", 1)
+	}
+	right := strings.HasSuffix(strings.TrimSpace(last.Text), ":")
+	if right {
+		pos := strings.LastIndex(last.Text, ":")
+		last.Text = last.Text[:pos] + last.Text[pos+1:]
+	}
+	switch {
+	case left && right:
+		return "center"
+	case left:
+		return "left"
+	case right:
+		return "right"
+	}
+	return ""
+}
+
+// Rowspan returns the number of rows spanned by a table cell DocumentNode.
+// For all other DocumentNodes, Rowspan returns 0.
+func (ctx *NodeContext) Rowspan() int {
+	if ctx.node == nil {
+		return 0
+	}
+	return ctx.node.Rowspan
+}
+
+// Align returns the alignment of a table cell, i.e. "left", "right", "center" or the empty string.
+func (ctx *NodeContext) Align() string {
+	if ctx.node == nil {
+		return ""
+	}
+	return ctx.node.align
+}
+
+// RowHeader returns true if a table cell is the header of its row.
+func (ctx *NodeContext) RowHeader() bool {
+	if ctx.node == nil {
+		return false
+	}
+	return ctx.node.rowHeader
+}
+
+// TableCaption returns the escaped caption of a table as specified by its attribute `caption`.
+func (ctx *NodeContext) TableCaption() string {
+	if ctx.node == nil || ctx.node.table == nil {
+		return ""
+	}
+	return html.EscapeString(ctx.node.table.caption)
+}
@@ -38,6 +38,10 @@ type PageContext struct {
 	gen           *HTMLGenerator
 	siteContext   interface{}
 	folderContext interface{}
+	// Pages linking to this page and pages linked from this page.
+	// These fields are populated by `Builder.collectLinks`.
+	backlinks     []*PageContext
+	outboundLinks []*PageContext
 }
 
 // Params returns a YAML map with attributes of the entire page.
@@ -60,6 +64,23 @@ func (ctx *PageContext) RelURL() string {
 	return ctx.page.RelURL
 }
 
+// OutputFormats returns all files generated for the page, one per output format.
+// Templates can use this to link to alternative representations of the page:
+//
+//	{{range .OutputFormats}}<link rel="alternate" type="{{.Format.MediaType}}" href="{{.RelURL}}">{{end}}
+func (ctx *PageContext) OutputFormats() []*PageOutput {
+	return ctx.page.Outputs
+}
+
+// OutputFormat returns the output format that is currently being generated or nil.
+// Its media type is available as `.OutputFormat.MediaType`.
+func (ctx *PageContext) OutputFormat() *OutputFormat {
+	if ctx.gen.output == nil {
+		return nil
+	}
+	return ctx.gen.output.Format
+}
+
 // Type returns the name of the page type
 func (ctx *PageContext) Type() string {
 	return ctx.page.PageTypeName
@@ -99,17 +120,38 @@ func (ctx *PageContext) Document() *NodeContext {
 }
 
 // Content returns the HTML representation of the page content.
+// The content is rendered once and reused by all output formats and by templates executed again by the builder.
 func (ctx *PageContext) Content() (string, error) {
+	if ctx.gen.content != nil {
+		return *ctx.gen.content, nil
+	}
 	str, err := ctx.gen.outerHTML(ctx.page.Document)
 	if err != nil {
 		println(fmt.Sprintf("ERRC: %v", err))
+		return str, err
 	}
-	return str, err
+	ctx.gen.content = &str
+	return str, nil
 }
 
+// Placeholders which `Scripts` and `Styles` return while an output is generated.
+// Templates, partials and functions such as `markdownify` can add resources after the
+// placeholder has been written. Hence, the placeholders are replaced once the output is complete.
+const (
+	scriptsPlaceholder = "\x00mates:scripts\x00"
+	stylesPlaceholder  = "\x00mates:styles\x00"
+)
+
 // Scripts returns a string that contains the HTML script tags required to load all scripts
 // required by the page content.
 func (ctx *PageContext) Scripts() string {
+	if ctx.gen.output != nil {
+		return scriptsPlaceholder
+	}
+	return ctx.scripts()
+}
+
+func (ctx *PageContext) scripts() string {
 	str := ""
 	for _, r := range ctx.gen.page.Resources {
 		if r.Type == ResourceTypeScript {
@@ -122,12 +164,23 @@ func (ctx *PageContext) Scripts() string {
 // Styles returns a string that contains the HTML style tags required to load all styles
 // required by the page content.
 func (ctx *PageContext) Styles() string {
+	if ctx.gen.output != nil {
+		return stylesPlaceholder
+	}
+	return ctx.styles()
+}
+
+func (ctx *PageContext) styles() string {
 	str := ""
 	for _, r := range ctx.gen.page.Resources {
 		if r.Type == ResourceTypeStyle {
 			str += r.ToHTMLLink()
 		}
 	}
+	// Code highlighted with CSS classes requires the style sheet of the highlight theme
+	if !ctx.page.HighlightInline && hasHighlightedCode(ctx.page.Document) {
+		str += "<style>" + ctx.HighlightCSS() + "</style>"
+	}
 	return str
 }
 
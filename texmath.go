@@ -0,0 +1,652 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// texToMathML converts a TeX formula to MathML.
+// It supports the common subset of LaTeX math: fractions, roots, sub- and superscripts, Greek letters,
+// operators, fonts, accents, delimiters, matrices and aligned equations.
+// If `display` is true, the result is a block formula.
+func texToMathML(tex string, display bool) (string, error) {
+	p := &texParser{src: tex}
+	items, err := p.parseRow()
+	if err != nil {
+		return "", err
+	}
+	if tok := p.peek(); tok != "" {
+		return "", fmt.Errorf("Unexpected %v", tok)
+	}
+	str := `<math xmlns="http://www.w3.org/1998/Math/MathML"`
+	if display {
+		str += ` display="block"`
+	}
+	str += "><semantics><mrow>" + strings.Join(items, "") + "</mrow>"
+	str += `<annotation encoding="application/x-tex">` + html.EscapeString(strings.TrimSpace(tex)) + "</annotation>"
+	return str + "</semantics></math>", nil
+}
+
+// texParser is a recursive descent parser which emits MathML while parsing.
+type texParser struct {
+	src string
+	pos int
+	// mathvariant applied to letters, e.g. "bold" inside of \mathbf
+	variant string
+}
+
+var texGreek = map[string]string{
+	"alpha": "α", "beta": "β", "gamma": "γ", "delta": "δ", "epsilon": "ϵ", "varepsilon": "ε", "zeta": "ζ",
+	"eta": "η", "theta": "θ", "vartheta": "ϑ", "iota": "ι", "kappa": "κ", "lambda": "λ", "mu": "μ", "nu": "ν",
+	"xi": "ξ", "pi": "π", "varpi": "ϖ", "rho": "ρ", "varrho": "ϱ", "sigma": "σ", "varsigma": "ς", "tau": "τ",
+	"upsilon": "υ", "phi": "ϕ", "varphi": "φ", "chi": "χ", "psi": "ψ", "omega": "ω",
+	"Gamma": "Γ", "Delta": "Δ", "Theta": "Θ", "Lambda": "Λ", "Xi": "Ξ", "Pi": "Π", "Sigma": "Σ",
+	"Upsilon": "Υ", "Phi": "Φ", "Psi": "Ψ", "Omega": "Ω",
+}
+
+// texIdentifiers are symbols rendered as identifiers rather than operators.
+var texIdentifiers = map[string]string{
+	"infty": "∞", "partial": "∂", "nabla": "∇", "emptyset": "∅", "varnothing": "∅", "hbar": "ℏ", "ell": "ℓ",
+	"aleph": "ℵ", "Re": "ℜ", "Im": "ℑ", "wp": "℘", "imath": "ı", "jmath": "ȷ",
+}
+
+var texOperators = map[string]string{
+	"cdot": "⋅", "times": "×", "div": "÷", "pm": "±", "mp": "∓", "ast": "∗", "star": "⋆", "circ": "∘",
+	"bullet": "∙", "oplus": "⊕", "ominus": "⊖", "otimes": "⊗", "odot": "⊙", "setminus": "∖",
+	"leq": "≤", "le": "≤", "geq": "≥", "ge": "≥", "neq": "≠", "ne": "≠", "approx": "≈", "equiv": "≡",
+	"sim": "∼", "simeq": "≃", "cong": "≅", "propto": "∝", "ll": "≪", "gg": "≫", "prec": "≺", "succ": "≻",
+	"in": "∈", "notin": "∉", "ni": "∋", "subset": "⊂", "supset": "⊃", "subseteq": "⊆", "supseteq": "⊇",
+	"cup": "∪", "cap": "∩", "wedge": "∧", "land": "∧", "vee": "∨", "lor": "∨", "neg": "¬", "lnot": "¬",
+	"forall": "∀", "exists": "∃", "nexists": "∄", "perp": "⊥", "parallel": "∥", "mid": "∣", "angle": "∠",
+	"to": "→", "rightarrow": "→", "leftarrow": "←", "leftrightarrow": "↔", "Rightarrow": "⇒",
+	"Leftarrow": "⇐", "Leftrightarrow": "⇔", "implies": "⟹", "iff": "⟺", "mapsto": "↦",
+	"uparrow": "↑", "downarrow": "↓", "longrightarrow": "⟶", "longleftarrow": "⟵",
+	"ldots": "…", "cdots": "⋯", "vdots": "⋮", "ddots": "⋱", "dots": "…", "colon": ":", "prime": "′",
+	"langle": "⟨", "rangle": "⟩", "lfloor": "⌊", "rfloor": "⌋", "lceil": "⌈", "rceil": "⌉",
+	"lbrace": "{", "rbrace": "}", "vert": "|", "Vert": "‖", "lvert": "|", "rvert": "|", "lVert": "‖", "rVert": "‖",
+	"{": "{", "}": "}", "|": "‖", "#": "#", "%": "%", "&": "&", "$": "$", "_": "_",
+}
+
+// texBigOperators have their limits above and below in display formulas.
+var texBigOperators = map[string]string{
+	"sum": "∑", "prod": "∏", "coprod": "∐", "bigcup": "⋃", "bigcap": "⋂", "bigoplus": "⨁", "bigotimes": "⨂",
+	"bigvee": "⋁", "bigwedge": "⋀",
+}
+
+// texIntegrals have their limits as sub- and superscripts.
+var texIntegrals = map[string]string{
+	"int": "∫", "iint": "∬", "iiint": "∭", "oint": "∮",
+}
+
+var texFunctions = wordSet(`sin cos tan cot sec csc arcsin arccos arctan sinh cosh tanh coth log ln lg exp
+	deg dim hom ker arg`)
+
+// texLimitFunctions are functions with limits such as "lim", which behave like big operators.
+var texLimitFunctions = wordSet(`lim liminf limsup max min sup inf det gcd Pr`)
+
+var texFonts = map[string]string{
+	"mathrm": "normal", "operatorname": "normal", "mathbf": "bold", "boldsymbol": "bold-italic", "mathit": "italic",
+	"mathbb": "double-struck", "mathcal": "script", "mathscr": "script", "mathfrak": "fraktur",
+	"mathsf": "sans-serif", "mathtt": "monospace",
+}
+
+var texAccents = map[string]string{
+	"hat": "^", "widehat": "^", "bar": "¯", "overline": "‾", "vec": "→", "overrightarrow": "→", "tilde": "~",
+	"widetilde": "~", "dot": "˙", "ddot": "¨", "check": "ˇ", "breve": "˘", "acute": "´", "grave": "`",
+	"overbrace": "⏞",
+}
+
+var texUnderAccents = map[string]string{
+	"underline": "_", "underbrace": "⏟",
+}
+
+var texSpaces = map[string]string{
+	",": "0.1667em", ":": "0.2222em", ">": "0.2222em", ";": "0.2778em", "!": "-0.1667em", " ": "0.25em",
+	"quad": "1em", "qquad": "2em", "enspace": "0.5em", "thinspace": "0.1667em",
+}
+
+var texDelimiterSizes = map[string]string{
+	"big": "1.2em", "bigl": "1.2em", "bigr": "1.2em", "Big": "1.8em", "Bigl": "1.8em", "Bigr": "1.8em",
+	"bigg": "2.4em", "biggl": "2.4em", "biggr": "2.4em", "Bigg": "3em", "Biggl": "3em", "Biggr": "3em",
+}
+
+// texMatrixFences maps matrix environments to their delimiters.
+var texMatrixFences = map[string][2]string{
+	"matrix": {"", ""}, "smallmatrix": {"", ""}, "pmatrix": {"(", ")"}, "bmatrix": {"[", "]"},
+	"Bmatrix": {"{", "}"}, "vmatrix": {"|", "|"}, "Vmatrix": {"‖", "‖"},
+}
+
+func (p *texParser) skipSpace() {
+	for p.pos < len(p.src) {
+		r, w := utf8.DecodeRuneInString(p.src[p.pos:])
+		if !unicode.IsSpace(r) {
+			return
+		}
+		p.pos += w
+	}
+}
+
+// next returns the next token, i.e. a command like "\frac", a number or a single character.
+// The result is empty at the end of the formula.
+func (p *texParser) next() string {
+	p.skipSpace()
+	if p.pos >= len(p.src) {
+		return ""
+	}
+	start := p.pos
+	c := p.src[p.pos]
+	switch {
+	case c == '\\':
+		p.pos++
+		for p.pos < len(p.src) && isASCIILetter(p.src[p.pos]) {
+			p.pos++
+		}
+		if p.pos == start+1 && p.pos < len(p.src) {
+			_, w := utf8.DecodeRuneInString(p.src[p.pos:])
+			p.pos += w
+		}
+	case c >= '0' && c <= '9':
+		for p.pos < len(p.src) && (p.src[p.pos] >= '0' && p.src[p.pos] <= '9' ||
+			p.src[p.pos] == '.' && p.pos+1 < len(p.src) && p.src[p.pos+1] >= '0' && p.src[p.pos+1] <= '9') {
+			p.pos++
+		}
+	default:
+		_, w := utf8.DecodeRuneInString(p.src[p.pos:])
+		p.pos += w
+	}
+	return p.src[start:p.pos]
+}
+
+func (p *texParser) peek() string {
+	pos := p.pos
+	tok := p.next()
+	p.pos = pos
+	return tok
+}
+
+func (p *texParser) expect(tok string) error {
+	if t := p.next(); t != tok {
+		if t == "" {
+			return fmt.Errorf("Expected %v at the end of the formula", tok)
+		}
+		return fmt.Errorf("Expected %v instead of %v", tok, t)
+	}
+	return nil
+}
+
+func isASCIILetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// isTerminator returns true for tokens which end a row: closing braces, table separators and the ends of environments and delimiters.
+func isTerminator(tok string, extra string) bool {
+	switch tok {
+	case "", "}", "&", `\\`, `\end`, `\right`, `\cr`:
+		return true
+	}
+	return tok == extra
+}
+
+// parseRow parses elements until a terminator or the token `extra` is reached, which is not consumed.
+func (p *texParser) parseRow(extra ...string) ([]string, error) {
+	stop := ""
+	if len(extra) > 0 {
+		stop = extra[0]
+	}
+	var items []string
+	for !isTerminator(p.peek(), stop) {
+		item, err := p.parseScripted()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func mrow(items []string) string {
+	if len(items) == 1 {
+		return items[0]
+	}
+	return "<mrow>" + strings.Join(items, "") + "</mrow>"
+}
+
+// parseGroup parses a row enclosed in braces.
+func (p *texParser) parseGroup() (string, error) {
+	if err := p.expect("{"); err != nil {
+		return "", err
+	}
+	items, err := p.parseRow()
+	if err != nil {
+		return "", err
+	}
+	return mrow(items), p.expect("}")
+}
+
+// parseRawGroup returns the text enclosed in braces without interpreting it, as needed by \text and \begin.
+func (p *texParser) parseRawGroup() (string, error) {
+	p.skipSpace()
+	if p.pos >= len(p.src) || p.src[p.pos] != '{' {
+		return "", errors.New("Expected {")
+	}
+	depth := 0
+	for i := p.pos; i < len(p.src); i++ {
+		switch p.src[i] {
+		case '\\':
+			i++
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				str := p.src[p.pos+1 : i]
+				p.pos = i + 1
+				return str, nil
+			}
+		}
+	}
+	return "", errors.New("Missing }")
+}
+
+// parseArgument parses the argument of a command or script, i.e. a group or a single element.
+func (p *texParser) parseArgument() (string, error) {
+	if p.peek() == "{" {
+		return p.parseGroup()
+	}
+	if isTerminator(p.peek(), "") || p.peek() == "^" || p.peek() == "_" {
+		return "", errors.New("Missing argument")
+	}
+	str, _, err := p.parseAtom()
+	return str, err
+}
+
+// parseScripted parses an element followed by sub- and superscripts.
+func (p *texParser) parseScripted() (string, error) {
+	base, limits, err := p.parseAtom()
+	if err != nil {
+		return "", err
+	}
+	var sub, sup string
+	for {
+		tok := p.peek()
+		if tok == "'" {
+			p.next()
+			sup += "<mo>′</mo>"
+			continue
+		}
+		if tok != "^" && tok != "_" {
+			break
+		}
+		p.next()
+		arg, err := p.parseArgument()
+		if err != nil {
+			return "", err
+		}
+		if tok == "^" {
+			if sup != "" {
+				return "", errors.New("Double superscript")
+			}
+			sup = arg
+		} else {
+			if sub != "" {
+				return "", errors.New("Double subscript")
+			}
+			sub = arg
+		}
+	}
+	switch {
+	case sub != "" && sup != "" && limits:
+		return "<munderover>" + base + sub + sup + "</munderover>", nil
+	case sub != "" && sup != "":
+		return "<msubsup>" + base + sub + sup + "</msubsup>", nil
+	case sub != "" && limits:
+		return "<munder>" + base + sub + "</munder>", nil
+	case sub != "":
+		return "<msub>" + base + sub + "</msub>", nil
+	case sup != "" && limits:
+		return "<mover>" + base + sup + "</mover>", nil
+	case sup != "":
+		return "<msup>" + base + sup + "</msup>", nil
+	}
+	return base, nil
+}
+
+// parseAtom parses a single element without scripts.
+// `limits` is true if scripts of the element are placed below and above it.
+func (p *texParser) parseAtom() (str string, limits bool, err error) {
+	pos := p.pos
+	tok := p.next()
+	switch {
+	case tok == "":
+		return "", false, errors.New("Unexpected end of formula")
+	case tok == "{":
+		items, err := p.parseRow()
+		if err != nil {
+			return "", false, err
+		}
+		return "<mrow>" + strings.Join(items, "") + "</mrow>", false, p.expect("}")
+	case tok == "^" || tok == "_":
+		// A script without base as in "{}^2" or "^2"
+		p.pos = pos
+		return "<mrow></mrow>", false, nil
+	case isTerminator(tok, ""):
+		return "", false, fmt.Errorf("Unexpected %v", tok)
+	case tok[0] >= '0' && tok[0] <= '9':
+		return "<mn>" + tok + "</mn>", false, nil
+	case tok[0] == '\\':
+		return p.parseCommand(tok[1:])
+	}
+	r, _ := utf8.DecodeRuneInString(tok)
+	if unicode.IsLetter(r) {
+		if p.variant == "" {
+			return "<mi>" + html.EscapeString(tok) + "</mi>", false, nil
+		}
+		// Letters in a font are combined, such that \mathrm{max} is a single identifier
+		for p.pos < len(p.src) && isASCIILetter(p.src[p.pos]) {
+			tok += p.src[p.pos : p.pos+1]
+			p.pos++
+		}
+		return `<mi mathvariant="` + p.variant + `">` + html.EscapeString(tok) + "</mi>", false, nil
+	}
+	switch tok {
+	case "-":
+		tok = "−"
+	case "*":
+		tok = "∗"
+	case "~":
+		return `<mspace width="0.25em"></mspace>`, false, nil
+	case "(", ")", "[", "]", "|":
+		return `<mo stretchy="false">` + tok + "</mo>", false, nil
+	}
+	return "<mo>" + html.EscapeString(tok) + "</mo>", false, nil
+}
+
+// parseCommand parses the command `name`, e.g. "frac" for "\frac".
+func (p *texParser) parseCommand(name string) (string, bool, error) {
+	if s, ok := texGreek[name]; ok {
+		if unicode.IsUpper([]rune(s)[0]) {
+			return `<mi mathvariant="normal">` + s + "</mi>", false, nil
+		}
+		return "<mi>" + s + "</mi>", false, nil
+	}
+	if s, ok := texIdentifiers[name]; ok {
+		return "<mi>" + s + "</mi>", false, nil
+	}
+	if s, ok := texOperators[name]; ok {
+		return "<mo>" + html.EscapeString(s) + "</mo>", false, nil
+	}
+	if s, ok := texBigOperators[name]; ok {
+		return `<mo largeop="true" movablelimits="true">` + s + "</mo>", true, nil
+	}
+	if s, ok := texIntegrals[name]; ok {
+		return `<mo largeop="true">` + s + "</mo>", false, nil
+	}
+	if texFunctions[name] {
+		return "<mi>" + name + "</mi>", false, nil
+	}
+	if texLimitFunctions[name] {
+		return `<mo form="prefix" movablelimits="true">` + name + "</mo>", true, nil
+	}
+	if width, ok := texSpaces[name]; ok {
+		return `<mspace width="` + width + `"></mspace>`, false, nil
+	}
+	if variant, ok := texFonts[name]; ok {
+		outer := p.variant
+		p.variant = variant
+		str, err := p.parseArgument()
+		p.variant = outer
+		return str, false, err
+	}
+	if accent, ok := texAccents[name]; ok {
+		arg, err := p.parseArgument()
+		return `<mover accent="true">` + arg + `<mo stretchy="true">` + html.EscapeString(accent) + "</mo></mover>", false, err
+	}
+	if accent, ok := texUnderAccents[name]; ok {
+		arg, err := p.parseArgument()
+		return `<munder accentunder="true">` + arg + `<mo stretchy="true">` + accent + "</mo></munder>", false, err
+	}
+	if size, ok := texDelimiterSizes[name]; ok {
+		delim, err := p.parseDelimiter()
+		return `<mo minsize="` + size + `" maxsize="` + size + `">` + delim + "</mo>", false, err
+	}
+	switch name {
+	case "frac", "dfrac", "tfrac", "cfrac":
+		num, err := p.parseArgument()
+		if err != nil {
+			return "", false, err
+		}
+		den, err := p.parseArgument()
+		return "<mfrac>" + num + den + "</mfrac>", false, err
+	case "binom":
+		n, err := p.parseArgument()
+		if err != nil {
+			return "", false, err
+		}
+		k, err := p.parseArgument()
+		return `<mrow><mo>(</mo><mfrac linethickness="0">` + n + k + "</mfrac><mo>)</mo></mrow>", false, err
+	case "sqrt":
+		if p.peek() == "[" {
+			p.next()
+			index, err := p.parseRow("]")
+			if err != nil {
+				return "", false, err
+			}
+			if err = p.expect("]"); err != nil {
+				return "", false, err
+			}
+			arg, err := p.parseArgument()
+			return "<mroot>" + arg + mrow(index) + "</mroot>", false, err
+		}
+		arg, err := p.parseArgument()
+		return "<msqrt>" + arg + "</msqrt>", false, err
+	case "text", "textrm", "textit", "textbf", "mbox":
+		text, err := p.parseRawGroup()
+		return "<mtext>" + html.EscapeString(unescapeEntityValue(text)) + "</mtext>", false, err
+	case "left":
+		open, err := p.parseDelimiter()
+		if err != nil {
+			return "", false, err
+		}
+		items, err := p.parseRow()
+		if err != nil {
+			return "", false, err
+		}
+		if err = p.expect(`\right`); err != nil {
+			return "", false, err
+		}
+		close, err := p.parseDelimiter()
+		str := "<mrow>"
+		if open != "" {
+			str += `<mo fence="true" stretchy="true">` + open + "</mo>"
+		}
+		str += strings.Join(items, "")
+		if close != "" {
+			str += `<mo fence="true" stretchy="true">` + close + "</mo>"
+		}
+		return str + "</mrow>", false, err
+	case "begin":
+		return p.parseEnvironment()
+	case "displaystyle", "textstyle", "limits", "nolimits":
+		return "", false, nil
+	}
+	return "", false, fmt.Errorf("Unknown command \\%v", name)
+}
+
+// parseDelimiter parses the delimiter following \left, \right or \big. The delimiter "." is empty.
+func (p *texParser) parseDelimiter() (string, error) {
+	tok := p.next()
+	switch tok {
+	case "(", ")", "[", "]", "|", "/":
+		return tok, nil
+	case ".":
+		return "", nil
+	case "<":
+		return "⟨", nil
+	case ">":
+		return "⟩", nil
+	case "":
+		return "", errors.New("Missing delimiter")
+	}
+	if tok[0] == '\\' {
+		if s, ok := texOperators[tok[1:]]; ok {
+			return html.EscapeString(s), nil
+		}
+	}
+	return "", fmt.Errorf("Unknown delimiter %v", tok)
+}
+
+// parseEnvironment parses the body of an environment such as "pmatrix", "cases" or "align".
+func (p *texParser) parseEnvironment() (string, bool, error) {
+	env, err := p.parseRawGroup()
+	if err != nil {
+		return "", false, err
+	}
+	fences, isMatrix := texMatrixFences[env]
+	switch {
+	case isMatrix, env == "cases", env == "align", env == "align*", env == "aligned", env == "split",
+		env == "gather", env == "gather*", env == "gathered", env == "array":
+	default:
+		return "", false, fmt.Errorf("Unknown environment %v", env)
+	}
+	if env == "array" {
+		// The column specification is ignored
+		if _, err := p.parseRawGroup(); err != nil {
+			return "", false, err
+		}
+	}
+	var rows [][]string
+	var row []string
+	columns := 0
+	for {
+		items, err := p.parseRow()
+		if err != nil {
+			return "", false, err
+		}
+		cell := strings.Join(items, "")
+		// In aligned equations, every second column starts with a relation such as "=", which needs a left operand
+		if strings.HasPrefix(env, "align") || env == "split" {
+			if len(row)%2 == 1 {
+				cell = "<mi></mi>" + cell
+			}
+		}
+		row = append(row, cell)
+		tok := p.next()
+		if tok == "&" {
+			continue
+		}
+		if tok == `\\` || tok == `\cr` || tok == `\end` {
+			// Ignore an empty last row
+			if tok != `\end` || len(row) > 1 || row[0] != "" {
+				rows = append(rows, row)
+				if len(row) > columns {
+					columns = len(row)
+				}
+			}
+			row = nil
+			if tok == `\\` || tok == `\cr` {
+				continue
+			}
+			end, err := p.parseRawGroup()
+			if err != nil {
+				return "", false, err
+			}
+			if end != env {
+				return "", false, fmt.Errorf("\\begin{%v} ended by \\end{%v}", env, end)
+			}
+			break
+		}
+		if tok == "" {
+			return "", false, fmt.Errorf("Missing \\end{%v}", env)
+		}
+		return "", false, fmt.Errorf("Unexpected %v", tok)
+	}
+	table := "<mtable"
+	switch {
+	case strings.HasPrefix(env, "align") || env == "split":
+		var align []string
+		for i := 0; i < columns; i++ {
+			align = append(align, []string{"right", "left"}[i%2])
+		}
+		table += ` displaystyle="true" columnalign="` + strings.Join(align, " ") + `" columnspacing="0em 2em"`
+	case strings.HasPrefix(env, "gather"):
+		table += ` displaystyle="true"`
+	case env == "cases":
+		table += ` columnalign="left left"`
+	}
+	table += ">"
+	for _, r := range rows {
+		table += "<mtr>"
+		for _, c := range r {
+			table += "<mtd>" + c + "</mtd>"
+		}
+		table += "</mtr>"
+	}
+	table += "</mtable>"
+	if env == "cases" {
+		return `<mrow><mo fence="true" stretchy="true">{</mo>` + table + "</mrow>", false, nil
+	}
+	if fences[0] != "" {
+		return `<mrow><mo fence="true" stretchy="true">` + fences[0] + "</mo>" + table + `<mo fence="true" stretchy="true">` + fences[1] + "</mo></mrow>", false, nil
+	}
+	return table, false, nil
+}
+
+// mathML returns true if the page type or site.yaml specify "Math: mathml".
+// A page type inherits the setting from its parent page type.
+func (p *pageType) mathML(siteDefault string) bool {
+	for pt := p; pt != nil; pt = pt.inheritPageType {
+		if pt.math != "" {
+			return pt.math == "mathml"
+		}
+	}
+	return siteDefault == "mathml"
+}
+
+// formula returns the MathML of the formula `tex`, which starts with the math node `node`.
+// If the formula cannot be converted, the result is empty and a warning is logged once per formula.
+func (gen *HTMLGenerator) formula(node *MathNode, tex string, display bool) string {
+	str, err := texToMathML(tex, display)
+	if err != nil {
+		if !node.warned {
+			log.Printf("%v:%v: Cannot convert formula to MathML: %v", gen.page.Fname, node.Line, err)
+			node.warned = true
+		}
+		return ""
+	}
+	return str
+}
+
+// inlineFormula returns the MathML of a formula in the text if the page type renders formulas at build time.
+func (gen *HTMLGenerator) inlineFormula(node *MathNode) string {
+	if !gen.page.MathML {
+		return ""
+	}
+	return gen.formula(node, node.Text, false)
+}
+
+// MathML returns the MathML of a #math node if the page type renders formulas at build time.
+// The result is empty otherwise or if the formula cannot be converted.
+func (ctx *NodeContext) MathML() string {
+	if ctx.node == nil || !ctx.gen.page.MathML || ctx.node.TagDefinition.SectionMode != sectionMath {
+		return ""
+	}
+	tex := ""
+	var first *MathNode
+	for _, t := range ctx.node.Text {
+		if m, ok := t.(*MathNode); ok {
+			if first == nil {
+				first = m
+			}
+			tex += m.Text
+		}
+	}
+	if first == nil {
+		return ""
+	}
+	return ctx.gen.formula(first, tex, true)
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// timelineTask is a row of a #timeline. Tasks without an end date are milestones.
+type timelineTask struct {
+	name       string
+	start, end float64
+	// Dates as written in the table, for tooltips
+	startText, endText string
+	milestone          bool
+	group              string
+	progress           float64
+	depends            []string
+	link               string
+	// Row of the task in the chart, counting group headers
+	row int
+}
+
+// Layout of the SVG of a #timeline
+const (
+	timelineRowHeight = 24
+	timelineTop       = 30
+	timelineBottom    = 10
+)
+
+// timeline renders a #timeline node as an SVG Gantt chart.
+//
+//	#timeline;today:2024-03-01
+//	|Task|Start|End|Group|Progress|Depends|Link|
+//	|Design|2024-01-08|2024-01-26|Phase 1|100%||docs/design.md|
+//	|Build|2024-01-29|2024-03-15|Phase 2|40%|Design||
+//	|Release|2024-03-18||Phase 2||Build||
+//
+// Start and End are dates as in charts. End dates without a time include the entire day. Rows without an end are milestones.
+// Depends lists the tasks which must be finished first, separated by commas. Link is a URL or a reference to a page as used by {ref}.
+// The chart marks the current day, or the date given by the attribute `today`.
+func (gen *HTMLGenerator) timeline(table *DocumentNode) (string, error) {
+	cols, _ := table.Columns()
+	var names []string
+	for _, c := range cols {
+		name := strings.TrimSpace(c.PlainText())
+		switch name {
+		case "Task", "Start", "End", "Group", "Progress", "Depends", "Link":
+		default:
+			log.Printf("Unknown column in timeline data: %v", name)
+		}
+		names = append(names, name)
+	}
+
+	var tasks []*timelineTask
+	byName := make(map[string]*timelineTask)
+	min, max := math.Inf(1), math.Inf(-1)
+	for _, row := range table.Rows() {
+		task := &timelineTask{}
+		startOk := false
+		col := 0
+		for _, cell := range row.DocumentNodes("#tbody-cell") {
+			if col >= len(names) {
+				break
+			}
+			// A cell spanning several columns leaves the following columns empty
+			name := names[col]
+			col += cell.Colspan
+			if cell.Colspan < 1 {
+				col++
+			}
+			t := strings.TrimSpace(cell.PlainText())
+			switch name {
+			case "Task":
+				task.name = t
+			case "Start":
+				if tm, ok := parseChartTime(t); ok {
+					task.start, task.startText, startOk = float64(tm.Unix()), t, true
+				} else {
+					log.Printf("Malformed date in timeline data: %v", t)
+				}
+			case "End":
+				if t == "" {
+					break
+				}
+				tm, ok := parseChartTime(t)
+				if !ok {
+					log.Printf("Malformed date in timeline data: %v", t)
+					break
+				}
+				// Dates without a time denote the entire day
+				if !strings.ContainsAny(t, "T ") && len(t) == len("2006-01-02") {
+					tm = tm.AddDate(0, 0, 1)
+				}
+				task.end, task.endText = float64(tm.Unix()), t
+			case "Group":
+				task.group = t
+			case "Progress":
+				if t == "" {
+					break
+				}
+				p, err := strconv.ParseFloat(strings.TrimSuffix(t, "%"), 64)
+				if err != nil {
+					log.Printf("Malformed progress in timeline data: %v", t)
+					break
+				}
+				task.progress = math.Max(0, math.Min(100, p)) / 100
+			case "Depends":
+				for _, d := range strings.Split(t, ",") {
+					if d = strings.TrimSpace(d); d != "" {
+						task.depends = append(task.depends, d)
+					}
+				}
+			case "Link":
+				if t == "" {
+					break
+				}
+				if strings.Contains(t, "://") || strings.HasPrefix(t, "#") {
+					task.link = t
+					break
+				}
+				href, err := gen.ref(t)
+				if err != nil {
+					return "", fmt.Errorf("%v:%v: %v", gen.page.Fname, cell.Line, err)
+				}
+				task.link = href
+			}
+		}
+		if !startOk {
+			continue
+		}
+		if task.end == 0 {
+			task.milestone = true
+			task.end = task.start
+		} else if task.end < task.start {
+			log.Printf("Task '%v' in timeline data ends before it starts", task.name)
+			task.end = task.start
+		}
+		min, max = math.Min(min, task.start), math.Max(max, task.end)
+		tasks = append(tasks, task)
+		byName[task.name] = task
+	}
+	if len(tasks) == 0 {
+		log.Printf("Missing Task and Start columns in timeline data")
+		return "", nil
+	}
+
+	// Tasks are listed by group, in the order in which the groups first appear
+	var groups []string
+	grouped := make(map[string][]*timelineTask)
+	for _, t := range tasks {
+		if _, ok := grouped[t.group]; !ok {
+			groups = append(groups, t.group)
+		}
+		grouped[t.group] = append(grouped[t.group], t)
+	}
+	headers := len(groups) > 1 || groups[0] != ""
+	rows := 0
+	var labels []string
+	for _, g := range groups {
+		if headers {
+			labels = append(labels, g)
+			rows++
+		}
+		for _, t := range grouped[g] {
+			t.row = rows
+			labels = append(labels, t.name)
+			rows++
+		}
+	}
+
+	today := float64(time.Now().UTC().Truncate(24 * time.Hour).Unix())
+	if v, ok := table.Attributes["today"]; ok {
+		if tm, ok := parseChartTime(v); ok {
+			today = float64(tm.Unix())
+		} else {
+			log.Printf("Malformed date in attribute today of timeline: %v", v)
+		}
+	}
+
+	// Leave some space around the tasks, at least half a day
+	pad := math.Max((max-min)/50, secondsPerDay/2)
+	axis := newChartAxis(min-pad, max+pad, false, true, false)
+	maxLen := 0
+	for _, l := range labels {
+		if n := len([]rune(l)); n > maxLen {
+			maxLen = n
+		}
+	}
+	left := math.Min(math.Max(float64(maxLen)*7+24, 80), chartWidth/3)
+	right := float64(chartWidth - chartMarginR)
+	height := timelineTop + rows*timelineRowHeight + timelineBottom
+	bottom := float64(height - timelineBottom)
+	x := func(v float64) float64 {
+		return left + axis.frac(v)*(right-left)
+	}
+	y := func(row int) float64 {
+		return float64(timelineTop + row*timelineRowHeight)
+	}
+
+	id := table.ForceID()
+	result := svgChartOpen(table, "timeline", chartWidth, height)
+	result += `<defs><marker id="` + id + `-arrow" viewBox="0 0 10 10" refX="10" refY="5" markerWidth="6" markerHeight="6" orient="auto"><path d="M0,0 L10,5 L0,10 Z" fill="#666"/></marker></defs>`
+
+	// Grid and time axis
+	result += `<g class="axis" stroke="#ddd">`
+	for _, t := range axis.ticks {
+		if axis.inside(t) {
+			result += fmt.Sprintf(`<line x1="%.2f" y1="%v" x2="%.2f" y2="%.2f"/>`, x(t), timelineTop, x(t), bottom)
+		}
+	}
+	result += fmt.Sprintf(`</g><rect x="%.2f" y="%v" width="%.2f" height="%.2f" fill="none" stroke="#999"/>`, left, timelineTop, right-left, bottom-timelineTop)
+	result += `<g class="ticks" fill="#545454">`
+	for _, t := range axis.ticks {
+		if axis.inside(t) {
+			result += fmt.Sprintf(`<text x="%.2f" y="%v" text-anchor="middle">%v</text>`, x(t), timelineTop-8, html.EscapeString(axis.label(t)))
+		}
+	}
+	result += `</g>`
+
+	// Group headers and task labels
+	result += `<g class="labels">`
+	row := 0
+	for _, g := range groups {
+		if headers {
+			result += fmt.Sprintf(`<text x="4" y="%.2f" font-weight="bold">%v</text>`, y(row)+16, html.EscapeString(g))
+			row++
+		}
+		for _, t := range grouped[g] {
+			indent := 4
+			if headers {
+				indent = 14
+			}
+			label := fmt.Sprintf(`<text x="%v" y="%.2f">%v</text>`, indent, y(t.row)+16, html.EscapeString(t.name))
+			result += timelineLink(t, label)
+			row++
+		}
+	}
+	result += `</g>`
+
+	// Dependencies run from the end of a task to the start of the dependent task
+	result += `<g class="dependencies" fill="none" stroke="#666">`
+	for _, t := range tasks {
+		for _, d := range t.depends {
+			dep, ok := byName[d]
+			if !ok {
+				log.Printf("Unknown task '%v' in dependencies of timeline task '%v'", d, t.name)
+				continue
+			}
+			x0, y0 := x(dep.end), y(dep.row)+12
+			x1, y1 := x(t.start), y(t.row)+12
+			result += fmt.Sprintf(`<path d="M%.2f,%.2f H%.2f V%.2f H%.2f" marker-end="url(#%v-arrow)"/>`, x0, y0, math.Max(x0+6, math.Min(x1-6, x0+12)), y1, x1, id)
+		}
+	}
+	result += `</g>`
+
+	// Bars and milestones, colored by group
+	for i, g := range groups {
+		color := chartColors[i%len(chartColors)]
+		result += `<g class="series">`
+		for _, t := range grouped[g] {
+			cy := y(t.row) + 12
+			var shape string
+			title := t.name + ": " + t.startText
+			if t.milestone {
+				cx := x(t.start)
+				shape = fmt.Sprintf(`<path d="M%.2f,%.2f L%.2f,%.2f L%.2f,%.2f L%.2f,%.2f Z" fill="%v" stroke="#333"><title>%v</title></path>`,
+					cx, cy-7, cx+7, cy, cx, cy+7, cx-7, cy, color, html.EscapeString(title))
+			} else {
+				x0, x1 := x(t.start), x(t.end)
+				title += " – " + t.endText
+				if t.progress > 0 {
+					title += fmt.Sprintf(" (%v%%)", math.Round(t.progress*100))
+				}
+				shape = fmt.Sprintf(`<rect x="%.2f" y="%.2f" width="%.2f" height="14" rx="3" fill="%v" fill-opacity="0.8" stroke="%v"><title>%v</title></rect>`,
+					x0, cy-7, x1-x0, color, color, html.EscapeString(title))
+				if t.progress > 0 {
+					shape += fmt.Sprintf(`<rect x="%.2f" y="%.2f" width="%.2f" height="14" rx="3" fill="#000" fill-opacity="0.25" pointer-events="none"/>`,
+						x0, cy-7, (x1-x0)*t.progress)
+				}
+			}
+			result += timelineLink(t, shape)
+		}
+		result += `</g>`
+	}
+
+	if axis.inside(today) {
+		result += fmt.Sprintf(`<g class="today" stroke="#c00"><line x1="%.2f" y1="%v" x2="%.2f" y2="%.2f" stroke-dasharray="4 3"/><text x="%.2f" y="%v" fill="#c00" stroke="none" text-anchor="middle" font-size="10">Today</text></g>`,
+			x(today), timelineTop, x(today), bottom, x(today), timelineTop+10)
+	}
+	return result + `</svg></div>`, nil
+}
+
+// timelineLink wraps the SVG of a task in a hyperlink, if the task has one.
+func timelineLink(t *timelineTask, svg string) string {
+	if t.link == "" {
+		return svg
+	}
+	return `<a href="` + html.EscapeString(t.link) + `">` + svg + `</a>`
+}
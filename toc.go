@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+)
+
+// Heading is an entry in the table of contents of a page.
+type Heading struct {
+	// Level of the heading, e.g. 2 for "##"
+	Level int
+	// HTML-id of the heading
+	ID string
+	// HTML of the heading text
+	Title string
+	// Text of the heading without markup
+	Text string
+	// Headings of a higher level following this heading
+	Children []*Heading
+}
+
+// parseTOCLevels parses a range of heading levels such as "2-3", "2-" or "2".
+func parseTOCLevels(spec string) (from int, to int, err error) {
+	f, t := spec, spec
+	if pos := strings.Index(spec, "-"); pos != -1 {
+		f, t = spec[:pos], spec[pos+1:]
+		if strings.TrimSpace(t) == "" {
+			t = "4"
+		}
+	}
+	from, err1 := strconv.Atoi(strings.TrimSpace(f))
+	to, err2 := strconv.Atoi(strings.TrimSpace(t))
+	if err1 != nil || err2 != nil || from < 1 || to > 4 || from > to {
+		return 0, 0, fmt.Errorf("Malformed heading levels '%v'. Expected a range like '2-3'", spec)
+	}
+	return from, to, nil
+}
+
+// tocLevels returns the range of heading levels as specified by "TOCLevels" in page.yaml.
+// A page type without "TOCLevels" inherits it from its parent page type.
+func (p *pageType) tocLevels() string {
+	for pt := p; pt != nil; pt = pt.inheritPageType {
+		if pt.tocLevelSpec != "" {
+			return pt.tocLevelSpec
+		}
+	}
+	return ""
+}
+
+// Headings returns the headings of the page as a tree.
+// Only headings in the range of levels configured by "TOCLevels" are listed.
+// Headings with the attribute "noid" are not listed, because the table of contents cannot link to them.
+func (ctx *PageContext) Headings() ([]*Heading, error) {
+	var result []*Heading
+	var stack []*Heading
+	var err error
+	var walk func(node *DocumentNode)
+	walk = func(node *DocumentNode) {
+		for _, c := range node.Children {
+			if err != nil {
+				return
+			}
+			level := c.TagDefinition.Heading
+			if level == 0 || level < ctx.page.TOCFrom || level > ctx.page.TOCTo || !c.isAnchoredHeading() {
+				walk(c)
+				continue
+			}
+			h := &Heading{Level: level, ID: c.ID(), Text: strings.TrimSpace(c.PlainText())}
+			if h.Title, err = ctx.gen.innerText(c); err != nil {
+				return
+			}
+			h.Title = strings.TrimSpace(h.Title)
+			for len(stack) > 0 && stack[len(stack)-1].Level >= level {
+				stack = stack[:len(stack)-1]
+			}
+			if len(stack) == 0 {
+				result = append(result, h)
+			} else {
+				parent := stack[len(stack)-1]
+				parent.Children = append(parent.Children, h)
+			}
+			stack = append(stack, h)
+			walk(c)
+		}
+	}
+	walk(ctx.page.Document)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// TableOfContents returns the headings of the page as nested HTML lists.
+// The result is empty if the page has no headings.
+func (ctx *PageContext) TableOfContents() (string, error) {
+	headings, err := ctx.Headings()
+	if err != nil {
+		return "", err
+	}
+	if len(headings) == 0 {
+		return "", nil
+	}
+	return `<nav class="toc">` + tocList(headings) + `</nav>`, nil
+}
+
+func tocList(headings []*Heading) string {
+	str := "<ul>"
+	for _, h := range headings {
+		str += `<li><a href="#` + html.EscapeString(h.ID) + `">` + h.Title + `</a>`
+		if len(h.Children) > 0 {
+			str += tocList(h.Children)
+		}
+		str += "</li>"
+	}
+	return str + "</ul>"
+}